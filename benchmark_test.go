@@ -0,0 +1,180 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duh-rpc/duh-go"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Run with `go test -run=^$ -bench=. -benchmem` to compare the allocations of each path
+//
+// Allocations of the 16KiB benchmarks before and after pooling of buffers and compression writers:
+//
+//	BenchmarkReadRequest/application/json/16384         83912 B/op -> 18840 B/op
+//	BenchmarkReadRequest/application/protobuf/16384     83256 B/op -> 18184 B/op
+//	BenchmarkReply/application/json/16384               19927 B/op ->  1762 B/op
+//	BenchmarkReply/application/json/16384/gzip        1096468 B/op ->  1929 B/op
+//	BenchmarkReply/application/protobuf/16384           19120 B/op ->  1008 B/op
+//	BenchmarkReply/application/protobuf/16384/gzip    1095424 B/op ->  1144 B/op
+//	BenchmarkClientDo/application/json/16384            85136 B/op -> 20065 B/op
+//	BenchmarkClientDo/application/protobuf/16384        84480 B/op -> 19408 B/op
+
+func benchmarkMessage(size int) *v1.Reply {
+	details := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		details[fmt.Sprintf("key-%d", i)] = fmt.Sprintf("value-%d", i)
+	}
+	return &v1.Reply{
+		Code:     duh.CodeOK,
+		CodeText: duh.CodeText(duh.CodeOK),
+		Message:  strings.Repeat("a", size),
+		Details:  details,
+	}
+}
+
+func benchmarkPayload(b *testing.B, codec duh.Codec, size int) []byte {
+	payload, err := codec.Marshal(benchmarkMessage(size))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return payload
+}
+
+var benchmarkSizes = []int{128, 16 * duh.Kibibyte}
+
+var benchmarkCodecs = []duh.Codec{duh.JSONCodec{}, duh.ProtoBufCodec{}}
+
+func BenchmarkReadRequest(b *testing.B) {
+	for _, codec := range benchmarkCodecs {
+		for _, size := range benchmarkSizes {
+			payload := benchmarkPayload(b, codec, size)
+			b.Run(fmt.Sprintf("%s/%d", codec.ContentType(), size), func(b *testing.B) {
+				body := bytes.NewReader(payload)
+				r := httptest.NewRequest(http.MethodPost, "/v1/bench.read", nil)
+				r.Header.Set("Content-Type", codec.ContentType())
+				r.ContentLength = int64(len(payload))
+				var m v1.Reply
+
+				b.ReportAllocs()
+				b.SetBytes(int64(len(payload)))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					body.Reset(payload)
+					r.Body = io.NopCloser(body)
+					if err := duh.ReadRequest(r, &m, duh.MegaByte); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// discardWriter is a http.ResponseWriter which discards the body, such that the benchmark only
+// measures the allocations made by Reply()
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func BenchmarkReply(b *testing.B) {
+	for _, codec := range benchmarkCodecs {
+		for _, size := range benchmarkSizes {
+			for _, encoding := range []string{"", duh.EncodingGzip} {
+				name := fmt.Sprintf("%s/%d", codec.ContentType(), size)
+				if encoding != "" {
+					name += "/" + encoding
+				}
+				b.Run(name, func(b *testing.B) {
+					m := benchmarkMessage(size)
+					r := httptest.NewRequest(http.MethodPost, "/v1/bench.reply", nil)
+					r.Header.Set("Accept", codec.ContentType())
+					r.Header.Set("Accept-Encoding", encoding)
+					w := &discardWriter{header: make(http.Header)}
+
+					b.ReportAllocs()
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						clear(w.header)
+						duh.Reply(w, r, duh.CodeOK, m)
+					}
+				})
+			}
+		}
+	}
+}
+
+// replayTransport is a http.RoundTripper which replies with the same payload to every request,
+// such that the benchmark measures the allocations of the client and not the network.
+type replayTransport struct {
+	contentType string
+	payload     []byte
+}
+
+func (t *replayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    duh.CodeOK,
+		Header:        http.Header{"Content-Type": []string{t.contentType}},
+		Body:          io.NopCloser(bytes.NewReader(t.payload)),
+		ContentLength: int64(len(t.payload)),
+		Request:       r,
+	}, nil
+}
+
+func BenchmarkClientDo(b *testing.B) {
+	for _, codec := range benchmarkCodecs {
+		for _, size := range benchmarkSizes {
+			payload := benchmarkPayload(b, codec, size)
+			b.Run(fmt.Sprintf("%s/%d", codec.ContentType(), size), func(b *testing.B) {
+				c := duh.Client{
+					Client: &http.Client{Transport: &replayTransport{
+						contentType: codec.ContentType(),
+						payload:     payload,
+					}},
+				}
+				req, err := http.NewRequest(http.MethodPost, "http://localhost/v1/bench.do", nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				req.Header.Set("Accept-Encoding", "identity")
+				var m v1.Reply
+
+				b.ReportAllocs()
+				b.SetBytes(int64(len(payload)))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					proto.Reset(&m)
+					if err := c.Do(req, &m); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/protobuf/proto"
+)
+
+// NewH2CHandler returns an http.Handler which serves HTTP/2 without TLS (h2c) in addition to HTTP/1,
+// such that clients like HTTP2Client can make bidirectional streaming calls to a service which
+// is not served over TLS.
+func NewH2CHandler(h http.Handler) http.Handler {
+	return h2c.NewHandler(h, &http2.Server{})
+}
+
+// BidiStream is a bidirectional stream where the client and server interleave messages on a single
+// request. Use NewBidiStream() to create a BidiStream.
+type BidiStream struct {
+	in  *RequestStream
+	out *StreamWriter
+}
+
+// NewBidiStream returns a BidiStream which receives messages streamed by the client in the request body
+// while sending messages to the client in the response. The request `Content-Type` and 'Accept' headers
+// must both be a supported stream content type, and each message received may not exceed 'limit' bytes.
+// If the headers are not supported, NewBidiStream returns an error with CodeClientContentError which
+// should be returned to the client via ReplyError().
+//
+// Bidirectional streams are intended to be served over HTTP/2 (h2 or h2c via NewH2CHandler) which is
+// full-duplex, NewBidiStream also enables full-duplex for HTTP/1 where supported. The response headers
+// are sent before NewBidiStream returns, such that the client knows the stream was accepted before it
+// sends the first message.
+//
+// The caller MUST call Close() once the method call is complete, such that the stream is terminated with a
+// trailer which informs the client of the success or failure of the method call. Cancellation by the client
+// is observed via the request context, after which Send() and Recv() return an error with CodeTransportError.
+//
+//	stream, err := duh.NewBidiStream(w, r, duh.MegaByte)
+//	if err != nil {
+//		duh.ReplyError(w, r, err)
+//		return
+//	}
+//
+//	for {
+//		var req v1.EchoRequest
+//		if err := stream.Recv(&req); err != nil {
+//			if errors.Is(err, io.EOF) {
+//				break
+//			}
+//			_ = stream.Close(err)
+//			return
+//		}
+//		if err := stream.Send(&v1.EchoResponse{Message: req.Message}); err != nil {
+//			_ = stream.Close(err)
+//			return
+//		}
+//	}
+//	_ = stream.Close(nil)
+func NewBidiStream(w http.ResponseWriter, r *http.Request, limit int64) (*BidiStream, error) {
+	in, err := ReadRequestStream(r, limit)
+	if err != nil {
+		return nil, err
+	}
+	out, err := NewStreamWriter(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// HTTP/2 is always full-duplex, so we only care if enabling full-duplex for HTTP/1 fails
+	if err := out.rc.EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return nil, NewServiceError(CodeInternalError, "while enabling full-duplex", err, nil)
+	}
+
+	out.start()
+	if err := out.rc.Flush(); err != nil {
+		return nil, NewServiceError(CodeTransportError, "while flushing stream headers", err, nil)
+	}
+	return &BidiStream{in: in, out: out}, nil
+}
+
+// Recv reads the next message sent by the client, returning io.EOF once the client has
+// ended its side of the stream. See RequestStream.Recv() for details.
+func (s *BidiStream) Recv(m proto.Message) error {
+	if err := s.out.r.Context().Err(); err != nil {
+		return NewServiceError(CodeTransportError, "request context is done", err, nil)
+	}
+	return s.in.Recv(m)
+}
+
+// Send sends the message to the client. See StreamWriter.Send() for details.
+func (s *BidiStream) Send(m proto.Message) error {
+	return s.out.Send(m)
+}
+
+// Close terminates the stream with a trailer which reports the error provided, or success if
+// the error is nil. See StreamWriter.Close() for details.
+func (s *BidiStream) Close(err error) error {
+	return s.out.Close(err)
+}
+
+// ClientBidiStream is the client side of a bidirectional stream. Use Client.DoBidiStream() to
+// create a ClientBidiStream.
+type ClientBidiStream struct {
+	*StreamReader
+	body *StreamBody
+	stop func() bool
+}
+
+// DoBidiStream starts a bidirectional stream with the server, where the client sends messages via Send()
+// while receiving messages from the server via Recv(). Send() and Recv() are safe to call from different
+// go routines. The request MUST NOT have a body. If the request has no `Content-Type` header the stream
+// uses JSON, and if the request has no 'Accept' header the server is asked to reply with the same content type.
+//
+// Bidirectional streams require a full-duplex transport, such as HTTP2Client. Cancelling the request
+// context cancels the stream for both the client and server. It is the callers responsibility to close
+// the returned ClientBidiStream.
+//
+//	stream, err := duh.HTTP2Client.DoBidiStream(req)
+//	if err != nil {
+//		return err
+//	}
+//	defer stream.Close()
+//
+//	go func() {
+//		for _, m := range messages {
+//			if err := stream.Send(m); err != nil {
+//				return
+//			}
+//		}
+//		_ = stream.CloseSend()
+//	}()
+//
+//	for {
+//		var resp v1.EchoResponse
+//		if err := stream.Recv(&resp); err != nil {
+//			if errors.Is(err, io.EOF) {
+//				break
+//			}
+//			return err
+//		}
+//	}
+func (c *Client) DoBidiStream(req *http.Request) (*ClientBidiStream, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		return nil, NewClientError("bidirectional stream request must not have a body", nil, nil)
+	}
+	// Avoid modifying the request of the caller
+	req = req.Clone(req.Context())
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", ContentTypeJSONStream)
+	}
+
+	contentType := strings.TrimSpace(strings.ToLower(TrimSuffix(req.Header.Get("Content-Type"), ";,")))
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", contentType)
+	}
+
+	body, err := newStreamBody(c.codecs(), contentType)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = body
+	req.GetBody = nil
+	req.ContentLength = -1
+
+	// The transport may block reading the request body while waiting for the next message
+	// to send, abort the body when the context is cancelled, such that the transport
+	// observes the cancellation.
+	stop := context.AfterFunc(req.Context(), func() {
+		body.CloseWithError(req.Context().Err())
+	})
+
+	reader, err := c.DoStream(req)
+	if err != nil {
+		stop()
+		_ = body.Close()
+		return nil, err
+	}
+	return &ClientBidiStream{StreamReader: reader, body: body, stop: stop}, nil
+}
+
+// Send marshals and sends the message to the server
+func (s *ClientBidiStream) Send(m proto.Message) error {
+	return s.body.Send(m)
+}
+
+// CloseSend ends the client side of the stream, informing the server that all
+// messages have been sent. The server can continue to send messages until it closes the stream.
+func (s *ClientBidiStream) CloseSend() error {
+	return s.body.CloseSend()
+}
+
+// Close closes both sides of the stream. If the stream has not been terminated by the server,
+// closing the stream cancels the stream.
+func (s *ClientBidiStream) Close() error {
+	s.stop()
+	s.body.CloseWithError(errors.New("stream closed by client"))
+	return s.StreamReader.Close()
+}
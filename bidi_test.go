@@ -0,0 +1,164 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBidiStream(t *testing.T) {
+	serverErr := make(chan error, 1)
+	server := httptest.NewServer(duh.NewH2CHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, err := duh.NewBidiStream(w, r, duh.Kibibyte)
+		if err != nil {
+			duh.ReplyError(w, r, err)
+			return
+		}
+
+		// Echo each message received back to the client
+		for {
+			var m test.ErrorsRequest
+			if err := stream.Recv(&m); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				serverErr <- err
+				_ = stream.Close(err)
+				return
+			}
+			if m.Case == "fail" {
+				_ = stream.Close(duh.NewServiceError(duh.CodeRequestFailed, "asked to fail", nil, nil))
+				return
+			}
+			if err := stream.Send(&test.ErrorsRequest{Case: "echo-" + m.Case}); err != nil {
+				_ = stream.Close(err)
+				return
+			}
+		}
+		_ = stream.Close(nil)
+	})))
+	defer server.Close()
+
+	start := func(t *testing.T, ctx context.Context, client *duh.Client, ct string) *duh.ClientBidiStream {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.echo", nil)
+		require.NoError(t, err)
+		if ct != "" {
+			req.Header.Set("Content-Type", ct)
+		}
+		stream, err := client.DoBidiStream(req)
+		require.NoError(t, err)
+		return stream
+	}
+
+	for _, tc := range []struct {
+		name   string
+		client *duh.Client
+		ct     string
+	}{
+		{name: "h2c json", client: duh.HTTP2Client},
+		{name: "h2c protobuf", client: duh.HTTP2Client, ct: duh.ContentTypeProtoBufStream},
+		{name: "http1 json", client: duh.HTTP1Client},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancel()
+			stream := start(t, ctx, tc.client, tc.ct)
+			defer func() { _ = stream.Close() }()
+
+			// Each message must be echoed before the next is sent, which is only
+			// possible if the stream is full-duplex.
+			for i := 0; i < 5; i++ {
+				require.NoError(t, stream.Send(&test.ErrorsRequest{Case: fmt.Sprintf("%d", i)}))
+				var m test.ErrorsRequest
+				require.NoError(t, stream.Recv(&m))
+				assert.Equal(t, fmt.Sprintf("echo-%d", i), m.Case)
+			}
+			require.NoError(t, stream.CloseSend())
+			assert.ErrorIs(t, stream.Recv(&test.ErrorsRequest{}), io.EOF)
+			assert.Equal(t, int32(duh.CodeOK), stream.Trailer().Code)
+		})
+	}
+
+	t.Run("does not modify the request", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.echo", nil)
+		require.NoError(t, err)
+		stream, err := duh.HTTP2Client.DoBidiStream(req)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+		assert.Equal(t, "", req.Header.Get("Content-Type"))
+		assert.Equal(t, "", req.Header.Get("Accept"))
+		assert.Nil(t, req.Body)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		stream := start(t, ctx, duh.HTTP2Client, "")
+		defer func() { _ = stream.Close() }()
+
+		require.NoError(t, stream.Send(&test.ErrorsRequest{Case: "fail"}))
+		err := stream.Recv(&test.ErrorsRequest{})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeRequestFailed, e.Code())
+		assert.Equal(t, "asked to fail", e.Message())
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := start(t, ctx, duh.HTTP2Client, "")
+		defer func() { _ = stream.Close() }()
+
+		require.NoError(t, stream.Send(&test.ErrorsRequest{Case: "0"}))
+		require.NoError(t, stream.Recv(&test.ErrorsRequest{}))
+		cancel()
+
+		err := stream.Recv(&test.ErrorsRequest{})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeTransportError, e.Code())
+
+		// The server observes the cancellation
+		select {
+		case err := <-serverErr:
+			require.True(t, errors.As(err, &e))
+			assert.Equal(t, duh.CodeTransportError, e.Code())
+		case <-time.After(5 * time.Second):
+			t.Fatal("server did not observe the cancellation")
+		}
+	})
+
+	t.Run("request with body", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/test.echo", bytes.NewReader([]byte("body")))
+		require.NoError(t, err)
+		_, err = duh.HTTP2Client.DoBidiStream(req)
+		require.Error(t, err)
+	})
+}
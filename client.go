@@ -15,6 +15,7 @@ limitations under the License.
 package duh
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/tls"
@@ -23,19 +24,59 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	v1 "github.com/duh-rpc/duh-go/proto/v1"
 	"golang.org/x/net/http2"
-	json "google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
 
 type Client struct {
 	Client *http.Client
+	// Interceptors is a list of interceptors which are called in the order provided for every call
+	// made via Do(), DoOctetStream() or DoStream(). The first interceptor is the outermost and is the
+	// first to see the request and the last to see the error returned.
+	Interceptors []Interceptor
+	// Codecs are used to un-marshal responses according to the `Content-Type` of the response.
+	// If nil, DefaultCodecs is used.
+	Codecs *Codecs
+	// ResponseLimit is the maximum number of bytes read from a response body, or a single message of a stream
+	// returned by DoStream(), after it is decompressed. If zero, DefaultResponseLimit is used. If negative,
+	// there is no limit on the size of the response.
+	ResponseLimit int64
+	// Compression configures the encodings listed in the `Accept-Encoding` header of calls made via Do()
+	// and used to decompress responses, and optionally compress request bodies. If nil, DefaultCompression
+	// is used. See Compression for details.
+	Compression *Compression
 }
 
+// DefaultResponseLimit is the maximum size of a response body read by the Client
+// if Client.ResponseLimit is not provided.
+const DefaultResponseLimit = 10 * MegaByte
+
+// Invoker makes the HTTP call for the request and un-marshals the response into the proto.Message
+type Invoker func(req *http.Request, out proto.Message) error
+
+// Interceptor intercepts calls made by the Client. An Interceptor can decorate the outgoing request
+// (IE: add authentication or tracing headers) before calling next, inspect the decoded proto.Message
+// or the error returned by next, or short-circuit the call by returning without calling next.
+//
+// Errors returned by next implement duh.Error and are typically a *ClientError which the interceptor
+// can inspect via errors.As(). When called by DoOctetStream() or DoStream(), out is nil.
+//
+//	client := &duh.Client{
+//		Client: http.DefaultClient,
+//		Interceptors: []duh.Interceptor{
+//			func(req *http.Request, out proto.Message, next duh.Invoker) error {
+//				req.Header.Set("Authorization", "Bearer "+token)
+//				return next(req, out)
+//			},
+//		},
+//	}
+type Interceptor func(req *http.Request, out proto.Message, next Invoker) error
+
 const (
 	DetailsHttpCode   = "http.code"
 	DetailsHttpUrl    = "http.url"
@@ -43,6 +84,8 @@ const (
 	DetailsHttpStatus = "http.status"
 	DetailsHttpBody   = "http.body"
 	DetailsCodeText   = "duh.code-text"
+	// DetailsResponseLimit is the Client.ResponseLimit in bytes which the response exceeded
+	DetailsResponseLimit = "duh.response-limit"
 )
 
 var (
@@ -83,31 +126,301 @@ var (
 	}
 )
 
-// TODO: Move this documentation to retry.UntilSuccess
-// DoWithRetry is identical to Do() except it will retry using the default retry.UntilSuccess which
-// will retry all requests that return one of the following status codes.
+// RetryPolicy retries the operation provided until it succeeds, the policy gives up or the context is
+// cancelled. retry.Policy implements this interface, see the `retry` package for details.
+type RetryPolicy interface {
+	On(ctx context.Context, operation func(context.Context, int) error) error
+}
+
+// DoWithRetry is identical to Do() except it will retry the request according to the RetryPolicy provided.
+// Using the retry.OnRetryable policy, DoWithRetry will retry all requests that return one of the
+// following status codes until the request succeeds or the request context is cancelled.
 //
+//	454 - Retry Request
+//	429 - Too Many Requests
 //	500 - Internal Error
 //	502 - Bad Gateway
 //	503 - Service Unavailable
-//	505 - Gateway Timeout
-//	429 - Too Many Requests
+//	504 - Gateway Timeout
 //
 // On 429 if the server provides a reset-time, DoWithRetry will calculate the appropriate retry time and
 // sleep until that time occurs or until the context is canceled.
+//
+// The request body is rebuilt for each attempt using http.Request.GetBody, which is set by
+// http.NewRequest() for common body types. If GetBody is nil the body is read into memory
+// before the first attempt, such that it can be re-sent on each attempt.
+//
+//	err := client.DoWithRetry(req, &resp, retry.OnRetryable)
+func (c *Client) DoWithRetry(req *http.Request, out proto.Message, p RetryPolicy) error {
+	getBody, err := rewindBody(req)
+	if err != nil {
+		return NewClientError("while reading request body: %w", err, map[string]string{
+			DetailsHttpUrl:    req.URL.String(),
+			DetailsHttpMethod: req.Method,
+		})
+	}
+
+	return p.On(req.Context(), func(ctx context.Context, attempt int) error {
+		r := req.Clone(ctx)
+		if getBody != nil {
+			body, err := getBody()
+			if err != nil {
+				return NewClientError("while rebuilding request body: %w", err, map[string]string{
+					DetailsHttpUrl:    req.URL.String(),
+					DetailsHttpMethod: req.Method,
+				})
+			}
+			r.Body = body
+		}
+		return c.Do(r, out)
+	})
+}
+
+// rewindBody returns a function which returns a new copy of the request body for each attempt
+func rewindBody(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		// Each attempt sends a new copy of the body, as such the original body is never sent
+		_ = req.Body.Close()
+		return req.GetBody, nil
+	}
+
+	b, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}, nil
+}
 
 // DoOctetStream sends the request and expects a `application/octet-stream` response from the server.
-// If server doesn't respond with `application/octet-stream` then it is assumed to be an error of v1.Reply
-// If the reply isn't a v1.Reply then the body of the response is returned as an error.
-// TODO: Implement this and clean this up
-func (c *Client) DoOctetStream(req *http.Request, code *int, r io.ReadCloser) error {
-	return nil
+// On success the response body is returned un-buffered as a stream, it is the callers responsibility
+// to close the returned io.ReadCloser. If the server doesn't respond with `application/octet-stream`
+// then the response is assumed to be a v1.Reply and is returned as an error. If the response isn't
+// a v1.Reply then the body of the response is returned as an infrastructure error.
+func (c *Client) DoOctetStream(req *http.Request) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := c.intercept(req, nil, func(req *http.Request, _ proto.Message) error {
+		// An interceptor could call the next Invoker more than once, IE: to retry the request
+		if body != nil {
+			_ = body.Close()
+		}
+		var err error
+		body, err = c.doOctetStream(req)
+		return err
+	})
+	if err != nil {
+		if body != nil {
+			_ = body.Close()
+		}
+		return nil, err
+	}
+	if body == nil {
+		return nil, NewClientError("an interceptor returned without calling the next Invoker", nil, nil)
+	}
+	return body, nil
+}
+
+func (c *Client) doOctetStream(req *http.Request) (io.ReadCloser, error) {
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, NewClientError("during client.Do(): %w", err, map[string]string{
+			DetailsHttpUrl:    req.URL.String(),
+			DetailsHttpMethod: req.Method,
+		})
+	}
+
+	mt := TrimSuffix(resp.Header.Get("Content-Type"), ";,")
+	if resp.StatusCode == CodeOK && strings.TrimSpace(strings.ToLower(mt)) == ContentOctetStream {
+		return resp.Body, nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return nil, c.unexpectedResponse(req, resp, ContentOctetStream)
+}
+
+// DoStream sends the request and expects a stream of messages from the server in the stream content type
+// of one of the client Codecs. If the request has no 'Accept' header, DoStream requests a JSON stream.
+// It is the callers responsibility to close the returned StreamReader.
+//
+// If the server doesn't respond with a stream, the response is assumed to be a v1.Reply and is
+// returned as an error. If the response isn't a v1.Reply then the body of the response is returned
+// as an infrastructure error.
+//
+//	stream, err := client.DoStream(req)
+//	if err != nil {
+//		return err
+//	}
+//	defer stream.Close()
+//
+//	for {
+//		var item v1.Item
+//		if err := stream.Recv(&item); err != nil {
+//			if errors.Is(err, io.EOF) {
+//				break
+//			}
+//			return err
+//		}
+//	}
+func (c *Client) DoStream(req *http.Request) (*StreamReader, error) {
+	if req.Header.Get("Accept") == "" {
+		// Avoid modifying the request of the caller
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept", ContentTypeJSONStream)
+	}
+
+	var stream *StreamReader
+	err := c.intercept(req, nil, func(req *http.Request, _ proto.Message) error {
+		// An interceptor could call the next Invoker more than once, IE: to retry the request
+		if stream != nil {
+			_ = stream.Close()
+		}
+		var err error
+		stream, err = c.doStream(req)
+		return err
+	})
+	if err != nil {
+		if stream != nil {
+			_ = stream.Close()
+		}
+		return nil, err
+	}
+	if stream == nil {
+		return nil, NewClientError("an interceptor returned without calling the next Invoker", nil, nil)
+	}
+	return stream, nil
+}
+
+func (c *Client) doStream(req *http.Request) (*StreamReader, error) {
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, NewClientError("during client.Do(): %w", err, map[string]string{
+			DetailsHttpUrl:    req.URL.String(),
+			DetailsHttpMethod: req.Method,
+		})
+	}
+
+	mt := TrimSuffix(resp.Header.Get("Content-Type"), ";,")
+	if codec, ok := streamCodec(c.codecs(), mt); ok && resp.StatusCode == CodeOK {
+		return &StreamReader{
+			body:  bufio.NewReader(resp.Body),
+			limit: c.responseLimit(),
+			codec: codec,
+			resp:  resp,
+			req:   req,
+		}, nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return nil, c.unexpectedResponse(req, resp, req.Header.Get("Accept"))
+}
+
+// unexpectedResponse returns an error for a response which does not have the expected content type.
+// If the response is a v1.Reply the reply is returned as an error, else the response is returned as
+// an infrastructure error.
+func (c *Client) unexpectedResponse(req *http.Request, resp *http.Response, expected string) error {
+	buf, err := c.readBody(req, resp)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(buf)
+	body := *buf
+
+	if !IsDUHCode(resp.StatusCode) {
+		return NewInfraError(req, resp, body)
+	}
+
+	mt := TrimSuffix(resp.Header.Get("Content-Type"), ";,")
+	codec, ok := c.codecs().Lookup(mt)
+	if !ok || mt == "" {
+		return NewInfraError(req, resp, body)
+	}
+
+	var reply v1.Reply
+	if err := unmarshalReply(codec, body, &reply); err != nil {
+		return NewInfraError(req, resp, body)
+	}
+
+	// The service replied with a v1.Reply when we expected something else
+	if resp.StatusCode == CodeOK {
+		return NewClientError(fmt.Sprintf("expected Content-Type '%s' but server replied with '%s'",
+			expected, mt), nil, map[string]string{
+			DetailsHttpUrl:    req.URL.String(),
+			DetailsHttpMethod: req.Method,
+			DetailsHttpStatus: resp.Status,
+		})
+	}
+	return NewReplyError(req, resp, &reply)
+}
+
+// NewRequest returns a new POST http.Request for the url provided, whose body is the message marshalled by
+// the codec for the content type provided. The codec is found via Client.Codecs, such that the options of
+// the codec, IE: JSONCodec.MarshalOptions, apply to requests. The 'Content-Type' and 'Accept' headers
+// are set to the content type provided.
+//
+//	req, err := client.NewRequest(ctx, "http://localhost:8080/v1/say.hello", duh.ContentTypeJSON, &in)
+//	if err != nil {
+//		return err
+//	}
+//	return client.Do(req, &out)
+func (c *Client) NewRequest(ctx context.Context, url, contentType string, in proto.Message) (*http.Request, error) {
+	codec, ok := c.codecs().Lookup(contentType)
+	if !ok {
+		return nil, NewClientError(fmt.Sprintf("unsupported content type '%s', only [%s] are supported",
+			contentType, strings.Join(c.codecs().ContentTypes(), ",")), nil, nil)
+	}
+
+	payload, err := codec.Marshal(in)
+	if err != nil {
+		return nil, NewClientError("while marshaling request payload: %w", err, nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, NewClientError("", err, nil)
+	}
+	req.Header.Set("Content-Type", codec.ContentType())
+	req.Header.Set("Accept", codec.ContentType())
+	return req, nil
 }
 
 // Do calls http.Client.Do() and un-marshals the response into the proto struct passed.
 // In the case of unexpected request or response errors, Do will return *duh.ClientError
 // with as much detail as possible.
 func (c *Client) Do(req *http.Request, out proto.Message) error {
+	return c.intercept(req, out, c.do)
+}
+
+// intercept calls the interceptors in order, with the last interceptor calling the Invoker provided
+func (c *Client) intercept(req *http.Request, out proto.Message, invoke Invoker) error {
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		next, interceptor := invoke, c.Interceptors[i]
+		invoke = func(req *http.Request, out proto.Message) error {
+			return interceptor(req, out, next)
+		}
+	}
+	return invoke(req, out)
+}
+
+func (c *Client) do(req *http.Request, out proto.Message) error {
+	r, err := c.compression().setCompressedBody(req)
+	if err != nil {
+		return NewClientError("while compressing request body: %w", err, map[string]string{
+			DetailsHttpUrl:    req.URL.String(),
+			DetailsHttpMethod: req.Method,
+		})
+	}
+	req = r
+
+	// If the caller has not set Accept-Encoding we list the encodings we support, which also
+	// disables the transparent gzip decompression of http.Transport, as we decompress ourselves.
+	if req.Header.Get("Accept-Encoding") == "" && len(c.compression().Encoders) != 0 {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", c.compression().AcceptEncoding())
+	}
+
 	// Preform the HTTP call
 	resp, err := c.Client.Do(req)
 	if err != nil {
@@ -118,68 +431,120 @@ func (c *Client) Do(req *http.Request, out proto.Message) error {
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	var body bytes.Buffer
-	// Copy the response into a buffer
-	if _, err = io.Copy(&body, resp.Body); err != nil {
-		return &ClientError{
-			err: fmt.Errorf("while reading response body: %w", err),
-			details: map[string]string{
-				DetailsHttpUrl:    req.URL.String(),
-				DetailsHttpMethod: req.Method,
-				DetailsHttpStatus: resp.Status,
-			},
-			code: CodeTransportError,
-		}
+	buf, err := c.readBody(req, resp)
+	if err != nil {
+		return err
 	}
+	defer putBuffer(buf)
+	body := *buf
 
 	// If we get a code that is not a known DUH code, then don't attempt to un-marshal,
 	// instead read the body and return an error
 	if !IsDUHCode(resp.StatusCode) {
-		return NewInfraError(req, resp, body.Bytes())
+		return NewInfraError(req, resp, body)
 	}
 
-	// Handle content negotiation and un-marshal the response
+	// Handle content negotiation and un-marshal the response. A response without a Content-Type
+	// is not from the service, as the service MUST always return the content type.
 	mt := TrimSuffix(resp.Header.Get("Content-Type"), ";,")
-	switch strings.TrimSpace(strings.ToLower(mt)) {
-	case ContentTypeJSON:
-		return c.handleJSONResponse(req, resp, body.Bytes(), out)
-	case ContentTypeProtoBuf:
-		return c.handleProtobufResponse(req, resp, body.Bytes(), out)
-	default:
-		return NewInfraError(req, resp, body.Bytes())
+	codec, ok := c.codecs().Lookup(mt)
+	if !ok || mt == "" {
+		return NewInfraError(req, resp, body)
 	}
+	return c.handleResponse(req, resp, body, codec, out)
 }
 
-func (c *Client) handleJSONResponse(req *http.Request, resp *http.Response, body []byte, out proto.Message) error {
-	if resp.StatusCode != CodeOK {
-		var reply v1.Reply
-		if err := json.Unmarshal(body, &reply); err != nil {
-			// Assume the body is not a Reply structure because
-			// the server is not respecting the spec.
-			return NewInfraError(req, resp, body)
+// codecs returns the codecs used to un-marshal responses
+func (c *Client) codecs() *Codecs {
+	if c.Codecs != nil {
+		return c.Codecs
+	}
+	return DefaultCodecs
+}
+
+// responseLimit returns the maximum number of bytes read from a response
+func (c *Client) responseLimit() int64 {
+	if c.ResponseLimit == 0 {
+		return DefaultResponseLimit
+	}
+	return c.ResponseLimit
+}
+
+// compression returns the Compression used by the client
+func (c *Client) compression() *Compression {
+	if c.Compression != nil {
+		return c.Compression
+	}
+	return DefaultCompression
+}
+
+// readBody copies the entire response body into a pooled buffer, decompressing the body according
+// to the `Content-Encoding` of the response. The caller should return the buffer to the pool via
+// putBuffer() once the body is no longer referenced.
+func (c *Client) readBody(req *http.Request, resp *http.Response) (*[]byte, error) {
+	limit := c.responseLimit()
+
+	// Use the Content-Length as a hint to the size of the buffer needed to read the body
+	size := resp.ContentLength
+	if limit > 0 && size > limit {
+		size = limit
+	}
+	buf := getBuffer(int(size))
+
+	r, err := decompress(c.compression(), resp.Header.Get("Content-Encoding"), resp.Body, CodeClientError)
+	if err == nil {
+		if limit > 0 {
+			r = NewLimitReader(r, limit)
 		}
-		return NewReplyError(req, resp, &reply)
+		*buf, err = readAppend(*buf, r)
+		_ = r.Close()
 	}
 
-	if err := json.Unmarshal(body, out); err != nil {
-		return NewServiceError(CodeClientError,
-			"", fmt.Errorf("while parsing response body '%s': %w", body, err), nil)
+	if err != nil {
+		putBuffer(buf)
+		details := map[string]string{
+			DetailsHttpUrl:    req.URL.String(),
+			DetailsHttpMethod: req.Method,
+			DetailsHttpStatus: resp.Status,
+		}
+
+		// The server replied with more than the client is willing to read. This is
+		// a client error, as the reply may be valid according to the server.
+		var limitErr *ErrDataLimitExceeded
+		if errors.As(err, &limitErr) {
+			details[DetailsResponseLimit] = strconv.FormatInt(limitErr.Max, 10)
+			return nil, NewClientError("", fmt.Errorf("response body %s; see Client.ResponseLimit",
+				limitErr.Message()), details)
+		}
+
+		// The response could not be decompressed
+		var e Error
+		if errors.As(err, &e) {
+			return nil, NewClientError("", fmt.Errorf("response body %s", e.Message()), details)
+		}
+		return nil, &ClientError{
+			err:     fmt.Errorf("while reading response body: %w", err),
+			details: details,
+			code:    CodeTransportError,
+		}
 	}
-	return nil
+	return buf, nil
 }
 
-func (c *Client) handleProtobufResponse(req *http.Request, resp *http.Response, body []byte, out proto.Message) error {
+func (c *Client) handleResponse(req *http.Request, resp *http.Response, body []byte, codec Codec,
+	out proto.Message) error {
 	if resp.StatusCode != CodeOK {
 		var reply v1.Reply
-		if err := proto.Unmarshal(body, &reply); err != nil {
+		if err := unmarshalReply(codec, body, &reply); err != nil {
+			// Assume the body is not a Reply structure because
+			// the server is not respecting the spec.
 			return NewInfraError(req, resp, body)
 		}
 		return NewReplyError(req, resp, &reply)
 	}
 
-	if err := proto.Unmarshal(body, out); err != nil {
-		return NewServiceError(CodeClientError,
-			"", fmt.Errorf("while parsing response body '%s': %w", body, err), nil)
+	if err := unmarshalPooled(codec, body, out); err != nil {
+		return NewServiceError(CodeClientError, "while parsing response body", err, nil)
 	}
 	return nil
 }
@@ -201,19 +566,27 @@ func NewReplyError(req *http.Request, resp *http.Response, reply *v1.Reply) erro
 		details[k] = v
 	}
 
-	// TODO: Handle CodeTooManyRequests, include a way to easily get those retry values
-	//  so retry.On() can get them.
-	return &ClientError{
-		code:    int(reply.Code),
-		msg:     reply.Message,
-		details: details,
+	e := &ClientError{
+		retryAfter: parseRetryAfter(resp.Header.Get(HeaderRetryAfter), time.Now()),
+		code:       int(reply.Code),
+		msg:        reply.Message,
+		details:    details,
+		typed:      reply.TypedDetails,
+	}
+
+	// Prefer the exact reset time provided in the details over the headers
+	if rl, ok := rateLimitFromDetails(reply.Details); ok {
+		e.rateLimit = &rl
+	} else if rl, ok := rateLimitFromHeaders(resp.Header, time.Now()); ok {
+		e.rateLimit = &rl
 	}
+	return e
 }
 
 // NewInfraError returns an error that originates from the infrastructure, and does not originate from
 // the client or service implementation.
 func NewInfraError(req *http.Request, resp *http.Response, body []byte) error {
-	return &ClientError{
+	e := &ClientError{
 		details: map[string]string{
 			DetailsHttpCode:   fmt.Sprintf("%d", resp.StatusCode),
 			DetailsHttpBody:   string(body),
@@ -221,10 +594,36 @@ func NewInfraError(req *http.Request, resp *http.Response, body []byte) error {
 			DetailsHttpStatus: resp.Status,
 			DetailsHttpMethod: req.Method,
 		},
+		retryAfter:   parseRetryAfter(resp.Header.Get(HeaderRetryAfter), time.Now()),
 		msg:          string(body),
 		code:         resp.StatusCode,
 		isInfraError: true,
 	}
+
+	// Infrastructure such as an API gateway could impose its own rate limit
+	if rl, ok := rateLimitFromHeaders(resp.Header, time.Now()); ok {
+		e.rateLimit = &rl
+	}
+	return e
+}
+
+// parseRetryAfter parses the `Retry-After` header as defined by RFC9110 which is either the
+// number of seconds to wait, or an HTTP date after which the request can be retried.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	h = strings.TrimSpace(h)
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseInt(h, 10, 64); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
 }
 
 // NewClientError returns an error that originates with the client code not from the service
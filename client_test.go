@@ -1,15 +1,22 @@
 package duh_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/duh-rpc/duh-go"
 	"github.com/duh-rpc/duh-go/internal/test"
+	"github.com/duh-rpc/duh-go/retry"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	json "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -159,3 +166,412 @@ func TestClientErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestClientDoOctetStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/test.download":
+			w.Header().Set("Content-Type", duh.ContentOctetStream)
+			w.WriteHeader(duh.CodeOK)
+			_, _ = w.Write([]byte("binary data"))
+		case "/v1/test.not-found":
+			duh.ReplyWithCode(w, r, duh.CodeNotFound, nil, "file not found")
+		case "/v1/test.wrong-content":
+			duh.Reply(w, r, duh.CodeOK, &test.ErrorsRequest{})
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("Bad Gateway"))
+		}
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	do := func(path string) (io.ReadCloser, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+path, nil)
+		require.NoError(t, err)
+		return duh.DefaultClient.DoOctetStream(req)
+	}
+
+	t.Run("returns the stream", func(t *testing.T) {
+		body, err := do("/v1/test.download")
+		require.NoError(t, err)
+		defer func() { _ = body.Close() }()
+		b, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, "binary data", string(b))
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		_, err := do("/v1/test.not-found")
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeNotFound, e.Code())
+		assert.Equal(t, "file not found", e.Message())
+	})
+
+	t.Run("unexpected content type", func(t *testing.T) {
+		_, err := do("/v1/test.wrong-content")
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientError, e.Code())
+		assert.Contains(t, e.Error(), "expected Content-Type 'application/octet-stream'")
+	})
+
+	t.Run("infrastructure error", func(t *testing.T) {
+		_, err := do("/v1/test.unknown")
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, http.StatusBadGateway, e.Code())
+		assert.Contains(t, e.Error(), "returned infrastructure error 502 with body: Bad Gateway")
+	})
+}
+
+func TestClientInterceptors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			duh.ReplyWithCode(w, r, duh.CodeUnauthorized, nil, "invalid token")
+			return
+		}
+		duh.Reply(w, r, duh.CodeOK, &test.ErrorsRequest{Case: "authorized"})
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	var order []string
+	var lastErr error
+	client := &duh.Client{
+		Client: http.DefaultClient,
+		Interceptors: []duh.Interceptor{
+			func(req *http.Request, out proto.Message, next duh.Invoker) error {
+				order = append(order, "first")
+				lastErr = next(req, out)
+				order = append(order, "first done")
+				return lastErr
+			},
+			func(req *http.Request, out proto.Message, next duh.Invoker) error {
+				order = append(order, "second")
+				if req.Header.Get("X-Short-Circuit") != "" {
+					return duh.NewClientError("short-circuit", nil, nil)
+				}
+				if req.Header.Get("X-No-Auth") == "" {
+					req.Header.Set("Authorization", "Bearer token")
+				}
+				return next(req, out)
+			},
+		},
+	}
+
+	newRequest := func(header string) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.auth", nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set(header, "true")
+		}
+		return req
+	}
+
+	t.Run("decorates the request", func(t *testing.T) {
+		order = nil
+		var resp test.ErrorsRequest
+		require.NoError(t, client.Do(newRequest(""), &resp))
+		assert.Equal(t, "authorized", resp.Case)
+		assert.Equal(t, []string{"first", "second", "first done"}, order)
+	})
+
+	t.Run("sees the final error", func(t *testing.T) {
+		order = nil
+		var resp test.ErrorsRequest
+		err := client.Do(newRequest("X-No-Auth"), &resp)
+		require.Error(t, err)
+		assert.Equal(t, err, lastErr)
+
+		var e *duh.ClientError
+		require.True(t, errors.As(lastErr, &e))
+		assert.Equal(t, duh.CodeUnauthorized, e.Code())
+		assert.Equal(t, "invalid token", e.Message())
+	})
+
+	t.Run("short-circuit", func(t *testing.T) {
+		order = nil
+		var resp test.ErrorsRequest
+		err := client.Do(newRequest("X-Short-Circuit"), &resp)
+		require.Error(t, err)
+		assert.Equal(t, "Client Error: short-circuit", err.Error())
+		assert.Equal(t, []string{"first", "second", "first done"}, order)
+	})
+
+	t.Run("applies to octet streams", func(t *testing.T) {
+		order = nil
+		// The server does not reply with an octet stream
+		_, err := client.DoOctetStream(newRequest(""))
+		require.Error(t, err)
+		assert.Equal(t, []string{"first", "second", "first done"}, order)
+	})
+
+	t.Run("next called twice closes the first response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v1/test.stream" {
+				s, _ := duh.NewStreamWriter(w, r)
+				_ = s.Close(nil)
+				return
+			}
+			duh.ReplyOctetStream(w, r, duh.CodeOK, strings.NewReader("octet stream"))
+		}))
+		defer server.Close()
+
+		transport := &closeTracker{RoundTripper: http.DefaultTransport}
+		client := &duh.Client{
+			Client: &http.Client{Transport: transport},
+			Interceptors: []duh.Interceptor{
+				func(req *http.Request, out proto.Message, next duh.Invoker) error {
+					_ = next(req, out)
+					return next(req, out)
+				},
+			},
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.octet", nil)
+		require.NoError(t, err)
+		body, err := client.DoOctetStream(req)
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, false}, transport.Closed())
+		require.NoError(t, body.Close())
+
+		transport.Reset()
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.stream", nil)
+		require.NoError(t, err)
+		stream, err := client.DoStream(req)
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, false}, transport.Closed())
+		require.NoError(t, stream.Close())
+	})
+}
+
+// closeTracker records if each response body returned by the RoundTripper was closed
+type closeTracker struct {
+	http.RoundTripper
+	mu     sync.Mutex
+	bodies []*trackedBody
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return b.ReadCloser.Close()
+}
+
+func (t *closeTracker) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := &trackedBody{ReadCloser: resp.Body}
+	t.bodies = append(t.bodies, b)
+	resp.Body = b
+	return resp, nil
+}
+
+func (t *closeTracker) Closed() []bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var closed []bool
+	for _, b := range t.bodies {
+		closed = append(closed, b.closed)
+	}
+	return closed
+}
+
+func (t *closeTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bodies = nil
+}
+
+func TestClientDoWithRetry(t *testing.T) {
+	var attempts int
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req test.ErrorsRequest
+		if err := duh.ReadRequest(r, &req, 0); err != nil {
+			duh.ReplyError(w, r, err)
+			return
+		}
+		bodies = append(bodies, req.Case)
+		attempts++
+
+		switch req.Case {
+		case "retry-twice":
+			if attempts < 3 {
+				duh.ReplyWithCode(w, r, duh.CodeRetryRequest, nil, "try again")
+				return
+			}
+		case "rate-limited":
+			if attempts < 2 {
+				w.Header().Set("Retry-After", "1")
+				duh.ReplyWithCode(w, r, duh.CodeTooManyRequests, nil, "slow down")
+				return
+			}
+		case "always-fail":
+			duh.ReplyWithCode(w, r, duh.CodeInternalError, nil, "internal error")
+			return
+		case "bad-request":
+			duh.ReplyWithCode(w, r, duh.CodeBadRequest, nil, "bad request")
+			return
+		}
+		duh.Reply(w, r, duh.CodeOK, &req)
+	}))
+	defer server.Close()
+
+	policy := retry.Policy{
+		Interval: retry.Sleep(10 * time.Millisecond),
+		OnCodes:  retry.RetryableCodes,
+		Attempts: 0,
+	}
+
+	newRequest := func(ctx context.Context, c string, rewind bool) *http.Request {
+		payload, err := json.Marshal(&test.ErrorsRequest{Case: c})
+		require.NoError(t, err)
+		// Hide the bytes.Reader from http.NewRequest such that GetBody is not set
+		var body io.Reader = bytes.NewReader(payload)
+		if !rewind {
+			body = io.NopCloser(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.retry", body)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", duh.ContentTypeJSON)
+		return req
+	}
+
+	for _, rewind := range []bool{true, false} {
+		t.Run(fmt.Sprintf("rebuilds the body GetBody=%t", rewind), func(t *testing.T) {
+			attempts, bodies = 0, nil
+			var resp test.ErrorsRequest
+			err := duh.DefaultClient.DoWithRetry(newRequest(context.Background(), "retry-twice", rewind), &resp, policy)
+			require.NoError(t, err)
+			assert.Equal(t, "retry-twice", resp.Case)
+			assert.Equal(t, []string{"retry-twice", "retry-twice", "retry-twice"}, bodies)
+		})
+	}
+
+	t.Run("closes the original body", func(t *testing.T) {
+		attempts, bodies = 0, nil
+		req := newRequest(context.Background(), "retry-twice", true)
+		body := &trackedBody{ReadCloser: req.Body}
+		req.Body = body
+
+		var resp test.ErrorsRequest
+		require.NoError(t, duh.DefaultClient.DoWithRetry(req, &resp, policy))
+		assert.True(t, body.closed)
+	})
+
+	t.Run("honors Retry-After", func(t *testing.T) {
+		attempts, bodies = 0, nil
+		var resp test.ErrorsRequest
+		start := time.Now()
+		err := duh.DefaultClient.DoWithRetry(newRequest(context.Background(), "rate-limited", true), &resp, policy)
+		require.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	})
+
+	t.Run("does not retry non-retryable codes", func(t *testing.T) {
+		attempts, bodies = 0, nil
+		var resp test.ErrorsRequest
+		err := duh.DefaultClient.DoWithRetry(newRequest(context.Background(), "bad-request", true), &resp, policy)
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeBadRequest, e.Code())
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		attempts, bodies = 0, nil
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		var resp test.ErrorsRequest
+		err := duh.DefaultClient.DoWithRetry(newRequest(ctx, "always-fail", true), &resp, policy)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Greater(t, attempts, 1)
+	})
+}
+
+func TestClientResponseLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/test.large":
+			duh.Reply(w, r, duh.CodeOK, &test.ErrorsRequest{Case: string(bytes.Repeat([]byte("a"), 2*duh.Kibibyte))})
+		case "/v1/test.stream":
+			s, err := duh.NewStreamWriter(w, r)
+			if err != nil {
+				duh.ReplyError(w, r, err)
+				return
+			}
+			_ = s.Send(&test.ErrorsRequest{Case: "small"})
+			_ = s.Send(&test.ErrorsRequest{Case: string(bytes.Repeat([]byte("a"), 2*duh.Kibibyte))})
+			_ = s.Close(nil)
+		}
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	newRequest := func(path string) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+path, nil)
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("reply exceeds limit", func(t *testing.T) {
+		c := duh.Client{Client: http.DefaultClient, ResponseLimit: duh.Kibibyte}
+		err := c.Do(newRequest("/v1/test.large"), &test.ErrorsRequest{})
+		require.Error(t, err)
+
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientError, e.Code())
+		assert.Equal(t, "Client Error: response body exceeds 1.0KiB limit; see Client.ResponseLimit", e.Error())
+		assert.Equal(t, "1024", e.Details()[duh.DetailsResponseLimit])
+		assert.Equal(t, "200 OK", e.Details()[duh.DetailsHttpStatus])
+		assert.Equal(t, server.URL+"/v1/test.large", e.Details()[duh.DetailsHttpUrl])
+	})
+
+	t.Run("negative limit is unlimited", func(t *testing.T) {
+		c := duh.Client{Client: http.DefaultClient, ResponseLimit: -1}
+		var resp test.ErrorsRequest
+		require.NoError(t, c.Do(newRequest("/v1/test.large"), &resp))
+		assert.Len(t, resp.Case, 2*duh.Kibibyte)
+	})
+
+	t.Run("default limit", func(t *testing.T) {
+		var resp test.ErrorsRequest
+		require.NoError(t, duh.DefaultClient.Do(newRequest("/v1/test.large"), &resp))
+		assert.Len(t, resp.Case, 2*duh.Kibibyte)
+	})
+
+	t.Run("stream message exceeds limit", func(t *testing.T) {
+		c := duh.Client{Client: http.DefaultClient, ResponseLimit: duh.Kibibyte}
+		stream, err := c.DoStream(newRequest("/v1/test.stream"))
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		var m test.ErrorsRequest
+		require.NoError(t, stream.Recv(&m))
+		assert.Equal(t, "small", m.Case)
+
+		err = stream.Recv(&m)
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientError, e.Code())
+		assert.Equal(t, "Client Error: stream message exceeds 1.0KiB limit; see Client.ResponseLimit", e.Error())
+		assert.Equal(t, "1024", e.Details()[duh.DetailsResponseLimit])
+	})
+}
@@ -65,7 +65,7 @@ func main() {
 	service := demo.NewService()
 
 	server := &http.Server{
-		Handler: &demo.Handler{Service: service},
+		Handler: demo.NewHandler(service),
 		Addr:    c.Address,
 	}
 
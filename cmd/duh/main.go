@@ -25,6 +25,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/duh-rpc/duh-go"
@@ -49,6 +50,7 @@ type config struct {
 	Address  string
 	Timeout  string
 	Verbose  bool
+	Methods  bool
 }
 
 func main() {
@@ -60,6 +62,8 @@ func main() {
 		"The duration to wait for a successful api call (default: 10s)")
 	f.BoolVar(&c.Verbose, "verbose", false,
 		"be verbose")
+	f.BoolVar(&c.Methods, "methods", false,
+		"list the methods of the service via the '"+duh.MethodsMethod+"' introspection method")
 	flag.Usage = func() {
 		_, _ = fmt.Fprintf(os.Stderr, "Usage: %s [flags] [/path/to/file.yaml]\n"+
 			"Flags:\n", os.Args[0])
@@ -67,6 +71,11 @@ func main() {
 	}
 	checkErr(f.Parse(os.Args[1:]), "while parsing command line args")
 
+	if c.Methods {
+		listMethods(c)
+		return
+	}
+
 	if len(f.Args()) == 0 {
 		log.Println("path to yaml file is required")
 		f.Usage()
@@ -139,6 +148,28 @@ func sendRequest(ctx context.Context, c config, api string, req any) error {
 	return nil
 }
 
+// listMethods prints the methods of the service, which must have registered duh.NewMethodsHandler()
+func listMethods(c config) {
+	ctx, cancel := context.WithTimeout(context.Background(), parseTimeout(c.Timeout))
+	defer cancel()
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("http://%s%s", c.Address, duh.MethodsMethod), bytes.NewReader([]byte("{}")))
+	checkErr(err, "while creating request")
+	r.Header.Set("Content-Type", duh.ContentTypeJSON)
+
+	var resp v1.MethodsResponse
+	checkErr(duh.DefaultClient.Do(r, &resp), "while listing methods")
+	for _, m := range resp.Methods {
+		fmt.Printf("%s\n", m.Path)
+		if m.RequestType != "" {
+			fmt.Printf("  request:       %s\n", m.RequestType)
+			fmt.Printf("  response:      %s\n", m.ResponseType)
+		}
+		fmt.Printf("  content types: %s\n", strings.Join(m.ContentTypes, ", "))
+	}
+}
+
 func process(c config, doc []byte, idx int) {
 	if len(bytes.TrimSpace(doc)) == 0 {
 		return
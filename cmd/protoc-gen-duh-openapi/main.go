@@ -0,0 +1,84 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// protoc-gen-duh-openapi is a protoc plugin which generates an OpenAPI 3.1 document for the DUH-RPC
+// methods of each proto file which defines services. The method paths match those generated by
+// protoc-gen-duh. See the openapi package for details.
+//
+// Install the plugin with `go install github.com/duh-rpc/duh-go/cmd/protoc-gen-duh-openapi` and add it
+// to buf.gen.yaml. The document is written to `<file>.openapi.yaml`, or `<file>.openapi.json` with the
+// `format=json` option. The `title` and `version` options set the info of the document.
+//
+//	plugins:
+//	  - name: duh-openapi
+//	    out: ./
+//	    opt: title=Users API,version=1.0.0
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/duh-rpc/duh-go/openapi"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func main() {
+	var flags flag.FlagSet
+	format := flags.String("format", "yaml", "the format of the generated document; 'yaml' or 'json'")
+	title := flags.String("title", "", "the title of the API")
+	version := flags.String("version", "", "the version of the API")
+
+	protogen.Options{ParamFunc: flags.Set}.Run(func(gen *protogen.Plugin) error {
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		if *format != "yaml" && *format != "json" {
+			return fmt.Errorf("invalid format '%s'; expected 'yaml' or 'json'", *format)
+		}
+
+		for _, f := range gen.Files {
+			if !f.Generate || len(f.Services) == 0 {
+				continue
+			}
+			methods, err := openapi.MethodsFromFile(f.Desc)
+			if err != nil {
+				return err
+			}
+
+			conf := openapi.Config{Title: *title, Version: *version}
+			if conf.Title == "" {
+				conf.Title = string(f.Desc.Package())
+			}
+			doc, err := openapi.Generate(conf, methods...)
+			if err != nil {
+				return fmt.Errorf("%s: %w", f.Desc.Path(), err)
+			}
+
+			var b []byte
+			if *format == "json" {
+				b, err = doc.JSON()
+			} else {
+				b, err = doc.YAML()
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", f.Desc.Path(), err)
+			}
+			g := gen.NewGeneratedFile(f.GeneratedFilenamePrefix+".openapi."+*format, "")
+			if _, err := g.Write(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
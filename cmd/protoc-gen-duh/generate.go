@@ -0,0 +1,196 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/duh-rpc/duh-go/internal/names"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// syntaxFieldNumber is the field number of `syntax` in descriptorpb.FileDescriptorProto
+const syntaxFieldNumber = 12
+
+// generateFile generates a `_duh.pb.go` file containing the clients, server interfaces and handlers
+// for all the services defined in the proto file.
+func generateFile(gen *protogen.Plugin, file *protogen.File) error {
+	version, err := methodVersion(file)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range file.Services {
+		for _, m := range s.Methods {
+			if m.Desc.IsStreamingClient() || m.Desc.IsStreamingServer() {
+				return fmt.Errorf("%s: streaming rpc '%s' is not supported", file.Desc.Path(), m.Desc.FullName())
+			}
+		}
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_duh.pb.go", file.GoImportPath)
+	genStandaloneComments(g, file, syntaxFieldNumber)
+	g.P("// Code generated by protoc-gen-duh. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+	genImports(g, file)
+
+	for _, s := range file.Services {
+		genService(g, s, version)
+	}
+	return nil
+}
+
+// genImports writes the import block for the packages used by the generated code. We avoid protogen
+// import management for these packages, as protogen would name the duh package `duh_go` after the
+// base of the import path. Imports of message types from other packages are still handled by protogen.
+func genImports(g *protogen.GeneratedFile, file *protogen.File) {
+	var methods int
+	for _, s := range file.Services {
+		methods += len(s.Methods)
+	}
+
+	g.P("import (")
+	if methods != 0 {
+		g.P(`"context"`)
+		g.P()
+	}
+	g.P(`"github.com/duh-rpc/duh-go"`)
+	g.P(")")
+	g.P()
+}
+
+// genStandaloneComments prints all leading comments for a FileDescriptorProto
+// location identified by the field number n. This ensures license headers are
+// copied into the generated file.
+func genStandaloneComments(g *protogen.GeneratedFile, f *protogen.File, n int32) {
+	loc := f.Desc.SourceLocations().ByPath(protoreflect.SourcePath{n})
+	for _, s := range loc.LeadingDetachedComments {
+		g.P(protogen.Comments(s))
+		g.P()
+	}
+	if s := loc.LeadingComments; s != "" {
+		g.P(protogen.Comments(s))
+		g.P()
+	}
+}
+
+func genService(g *protogen.GeneratedFile, s *protogen.Service, version string) {
+	serverName := s.GoName + "Server"
+	clientName := s.GoName + "Client"
+
+	// Method path constants
+	g.P("const (")
+	for _, m := range s.Methods {
+		g.P(methodConst(s, m), " = ", fmt.Sprintf("%q", methodPath(version, s, m)))
+	}
+	g.P(")")
+	g.P()
+
+	// Server interface
+	g.P("// ", serverName, " is the server API for the ", s.GoName, " service.")
+	g.P("type ", serverName, " interface {")
+	for _, m := range s.Methods {
+		g.P(m.Comments.Leading, m.GoName, "(ctx context.Context, req *", m.Input.GoIdent,
+			", resp *", m.Output.GoIdent, ") error")
+	}
+	g.P("}")
+	g.P()
+
+	// Router registration
+	g.P("// Register", serverName, " registers the methods of the ", serverName, " with the duh.Router provided")
+	g.P("func Register", serverName, "(r *duh.Router, srv ", serverName, ", conf duh.HandlerConfig) {")
+	for _, m := range s.Methods {
+		g.P("r.Handle(", methodConst(s, m), ", duh.NewHandler(srv.", m.GoName, ", conf))")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// New", s.GoName, "Handler returns a duh.Router which routes method calls to the ", serverName)
+	g.P("func New", s.GoName, "Handler(srv ", serverName, ", conf duh.HandlerConfig) *duh.Router {")
+	g.P("r := duh.NewRouter()")
+	g.P("Register", serverName, "(r, srv, conf)")
+	g.P("return r")
+	g.P("}")
+	g.P()
+
+	// Client
+	g.P("// ", clientName, " is a client for the ", s.GoName, " service which uses protobuf serialization, unless")
+	g.P("// another content type is provided via WithContentType().")
+	g.P("type ", clientName, " struct {")
+	g.P("client      *duh.Client")
+	g.P("endpoint    string")
+	g.P("contentType string")
+	g.P("}")
+	g.P()
+
+	g.P("// New", clientName, " returns a new ", clientName, " which makes method calls to the service at the")
+	g.P("// endpoint provided, IE: `http://localhost:8080`. If client is nil, duh.DefaultClient is used.")
+	g.P("func New", clientName, "(client *duh.Client, endpoint string) *", clientName, " {")
+	g.P("if client == nil {")
+	g.P("client = duh.DefaultClient")
+	g.P("}")
+	g.P("return &", clientName, "{client: client, endpoint: endpoint, contentType: duh.ContentTypeProtoBuf}")
+	g.P("}")
+	g.P()
+
+	g.P("// WithContentType returns a copy of the ", clientName, " which encodes requests and accepts replies in the")
+	g.P("// content type provided, IE: duh.ContentTypeJSON. The content type must be one of the duh.Client.Codecs.")
+	g.P("func (c *", clientName, ") WithContentType(contentType string) *", clientName, " {")
+	g.P("cp := *c")
+	g.P("cp.contentType = contentType")
+	g.P("return &cp")
+	g.P("}")
+	g.P()
+
+	for _, m := range s.Methods {
+		genClientMethod(g, s, m, clientName)
+	}
+}
+
+func genClientMethod(g *protogen.GeneratedFile, s *protogen.Service, m *protogen.Method, clientName string) {
+	g.P(m.Comments.Leading, "func (c *", clientName, ") ", m.GoName, "(ctx context.Context, req *",
+		m.Input.GoIdent, ", resp *", m.Output.GoIdent, ") error {")
+	g.P("r, err := c.client.NewRequest(ctx, c.endpoint+", methodConst(s, m), ", c.contentType, req)")
+	g.P("if err != nil {")
+	g.P("return err")
+	g.P("}")
+	g.P("return c.client.Do(r, resp)")
+	g.P("}")
+	g.P()
+}
+
+// methodVersion returns the version used in the DUH-RPC method path which is derived
+// from the last element of the proto package. IE: `package duh.v1;` results in `v1`
+func methodVersion(file *protogen.File) (string, error) {
+	version, err := names.Version(file.Desc.Package())
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", file.Desc.Path(), err)
+	}
+	return version, nil
+}
+
+// methodConst returns the name of the constant which holds the method path
+func methodConst(s *protogen.Service, m *protogen.Method) string {
+	return s.GoName + m.GoName + "Method"
+}
+
+// methodPath returns the DUH-RPC method path in the form `/<version>/<service>.<rpc>`
+func methodPath(version string, s *protogen.Service, m *protogen.Method) string {
+	return names.MethodPath(version, s.Desc.Name(), m.Desc.Name())
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/duh-rpc/duh-go/demo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func generate(t *testing.T, fd *descriptorpb.FileDescriptorProto) (*pluginpb.CodeGeneratorResponse, error) {
+	t.Helper()
+	gen, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{fd.GetName()},
+		Parameter:      proto.String("paths=source_relative"),
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{fd},
+	})
+	require.NoError(t, err)
+
+	for _, f := range gen.Files {
+		if f.Generate {
+			if err := generateFile(gen, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return gen.Response(), nil
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("demo", func(t *testing.T) {
+		resp, err := generate(t, protodesc.ToFileDescriptorProto(demo.File_demo_demo_proto))
+		require.NoError(t, err)
+		require.Nil(t, resp.Error)
+		require.Len(t, resp.File, 1)
+		assert.Equal(t, "demo/demo_duh.pb.go", resp.File[0].GetName())
+
+		content := resp.File[0].GetContent()
+		assert.Contains(t, content, `SayHelloMethod = "/v1/say.hello"`)
+		assert.Contains(t, content, `RenderPixelMethod = "/v1/render.pixel"`)
+		assert.Contains(t, content, "type SayServer interface {")
+		assert.Contains(t, content, "func RegisterSayServer(r *duh.Router, srv SayServer, conf duh.HandlerConfig) {")
+		assert.Contains(t, content, "func (c *RenderClient) Pixel(ctx context.Context, req *RenderPixelRequest, "+
+			"resp *RenderPixelResponse) error {")
+		assert.Contains(t, content, "func (c *SayClient) WithContentType(contentType string) *SayClient {")
+	})
+
+	t.Run("package must be versioned", func(t *testing.T) {
+		fd := protodesc.ToFileDescriptorProto(demo.File_demo_demo_proto)
+		fd.Package = proto.String("duh")
+		for _, m := range fd.Service[0].Method {
+			m.InputType = proto.String(".duh.SayHelloRequest")
+			m.OutputType = proto.String(".duh.SayHelloResponse")
+		}
+		for _, m := range fd.Service[1].Method {
+			m.InputType = proto.String(".duh.RenderPixelRequest")
+			m.OutputType = proto.String(".duh.RenderPixelResponse")
+		}
+		_, err := generate(t, fd)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "package 'duh' must end with a version")
+	})
+
+	t.Run("streaming is not supported", func(t *testing.T) {
+		fd := protodesc.ToFileDescriptorProto(demo.File_demo_demo_proto)
+		fd.Service[0].Method[0].ServerStreaming = proto.Bool(true)
+		_, err := generate(t, fd)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "streaming rpc 'duh.v1.Say.Hello' is not supported")
+	})
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// protoc-gen-duh is a protoc plugin which generates DUH-RPC clients, server interfaces and
+// duh.Router backed handlers from protobuf service definitions.
+//
+// Each rpc is mapped to a DUH-RPC method path in the form `/<version>/<service>.<rpc>` where
+// the version is the last element of the proto package (IE: `v1` for `package duh.v1;`) and the
+// service and rpc names are converted to lower kebab-case. For example
+//
+//	package users.v1;
+//
+//	service Users {
+//	  rpc Create(CreateRequest) returns (CreateResponse);
+//	}
+//
+// results in the method path `/v1/users.create`
+//
+// Install the plugin with `go install github.com/duh-rpc/duh-go/cmd/protoc-gen-duh` and add it to
+// buf.gen.yaml alongside protoc-gen-go
+//
+//	plugins:
+//	  - name: go
+//	    out: ./
+//	    opt: paths=source_relative
+//	  - name: duh
+//	    out: ./
+//	    opt: paths=source_relative
+package main
+
+import (
+	"flag"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func main() {
+	var flags flag.FlagSet
+	protogen.Options{ParamFunc: flags.Set}.Run(func(gen *protogen.Plugin) error {
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		for _, f := range gen.Files {
+			if !f.Generate || len(f.Services) == 0 {
+				continue
+			}
+			if err := generateFile(gen, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
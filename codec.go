@@ -0,0 +1,330 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	json "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec marshals and un-marshals messages for a single content type
+type Codec interface {
+	// ContentType returns the mime type handled by the codec, IE: `application/json`
+	ContentType() string
+	// Marshal returns the encoded form of the message provided
+	Marshal(m proto.Message) ([]byte, error)
+	// Unmarshal decodes the []byte provided into the message provided
+	Unmarshal(b []byte, m proto.Message) error
+}
+
+// MarshalAppender is an optional interface implemented by a Codec which can append the encoded form of
+// a message to an existing buffer. Reply() uses MarshalAppend to marshal into pooled buffers, which
+// avoids allocating a new buffer for every reply.
+type MarshalAppender interface {
+	// MarshalAppend appends the encoded form of the message to b and returns the extended buffer
+	MarshalAppend(b []byte, m proto.Message) ([]byte, error)
+}
+
+// marshalAppend appends the encoded form of the message to b using MarshalAppend if the codec
+// implements MarshalAppender.
+func marshalAppend(c Codec, b []byte, m proto.Message) ([]byte, error) {
+	if a, ok := c.(MarshalAppender); ok {
+		return a.MarshalAppend(b, m)
+	}
+	encoded, err := c.Marshal(m)
+	if err != nil {
+		return b, err
+	}
+	return append(b, encoded...), nil
+}
+
+// PooledUnmarshaler is an optional interface implemented by a Codec which does not retain the []byte
+// provided after un-marshalling returns. ReadRequest() and Client un-marshal directly from pooled buffers
+// if the codec implements PooledUnmarshaler, else the []byte is copied before calling Unmarshal, such that
+// codecs which alias the []byte in the decoded message are not affected when the buffer is reused.
+type PooledUnmarshaler interface {
+	// UnmarshalPooled decodes the []byte provided into the message provided without retaining the []byte
+	UnmarshalPooled(b []byte, m proto.Message) error
+}
+
+// unmarshalPooled decodes the pooled buffer provided into the message using UnmarshalPooled if the codec
+// implements PooledUnmarshaler, else it un-marshals a copy of the buffer.
+func unmarshalPooled(c Codec, b []byte, m proto.Message) error {
+	if u, ok := c.(PooledUnmarshaler); ok {
+		return u.UnmarshalPooled(b, m)
+	}
+	return c.Unmarshal(bytes.Clone(b), m)
+}
+
+// replyCodec returns the codec used to marshal the message provided. A v1.Reply is always encoded in JSON
+// with the default options, such that the keys on the wire match the DUH-RPC spec regardless of the
+// MarshalOptions of the negotiated codec, IE: `code_text` and not `codeText` when UseProtoNames is set.
+func replyCodec(c Codec, m proto.Message) Codec {
+	if _, ok := m.(*v1.Reply); ok && c.ContentType() == ContentTypeJSON {
+		return JSONCodec{}
+	}
+	return c
+}
+
+// JSONCodec is a Codec for `application/json` which uses protojson. The zero value uses the protojson
+// defaults, which omit unpopulated fields and reject unknown fields. To tolerate fields added by newer
+// versions of a service or client, set UnmarshalOptions.DiscardUnknown.
+//
+//	codec := duh.JSONCodec{
+//		MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true, UseProtoNames: true},
+//		UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+//	}
+//	client := &duh.Client{
+//		Client: http.DefaultClient,
+//		Codecs: duh.NewCodecs(codec, duh.ProtoBufCodec{}),
+//	}
+type JSONCodec struct {
+	// MarshalOptions are used when marshalling messages, IE: EmitUnpopulated, UseProtoNames and UseEnumNumbers.
+	// The options do not apply to v1.Reply, which is always marshalled with the defaults as the spec requires.
+	MarshalOptions json.MarshalOptions
+	// UnmarshalOptions are used when un-marshalling messages, IE: DiscardUnknown
+	UnmarshalOptions json.UnmarshalOptions
+}
+
+func (c JSONCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (c JSONCodec) Marshal(m proto.Message) ([]byte, error) {
+	return c.MarshalOptions.Marshal(m)
+}
+
+func (c JSONCodec) MarshalAppend(b []byte, m proto.Message) ([]byte, error) {
+	return c.MarshalOptions.MarshalAppend(b, m)
+}
+
+func (c JSONCodec) Unmarshal(b []byte, m proto.Message) error {
+	return c.UnmarshalOptions.Unmarshal(b, m)
+}
+
+// UnmarshalPooled implements PooledUnmarshaler, as protojson copies all values out of the []byte
+func (c JSONCodec) UnmarshalPooled(b []byte, m proto.Message) error {
+	return c.UnmarshalOptions.Unmarshal(b, m)
+}
+
+// ProtoBufCodec is a Codec for `application/protobuf`
+type ProtoBufCodec struct{}
+
+func (ProtoBufCodec) ContentType() string                       { return ContentTypeProtoBuf }
+func (ProtoBufCodec) Marshal(m proto.Message) ([]byte, error)   { return proto.Marshal(m) }
+func (ProtoBufCodec) Unmarshal(b []byte, m proto.Message) error { return proto.Unmarshal(b, m) }
+
+func (ProtoBufCodec) MarshalAppend(b []byte, m proto.Message) ([]byte, error) {
+	return proto.MarshalOptions{}.MarshalAppend(b, m)
+}
+
+// UnmarshalPooled implements PooledUnmarshaler, as proto.Unmarshal() copies bytes fields out of the []byte
+func (ProtoBufCodec) UnmarshalPooled(b []byte, m proto.Message) error {
+	return proto.Unmarshal(b, m)
+}
+
+// DefaultCodecs are the codecs used by ReadRequest(), Reply() and Client if no other codecs are provided
+var DefaultCodecs = NewCodecs(JSONCodec{}, ProtoBufCodec{})
+
+// Negotiation is the method used by Codecs.Negotiate() to select a codec from the `Accept` header
+type Negotiation int
+
+const (
+	// NegotiateStrict uses the first mime type in the `Accept` header and ignores any mime type parameters
+	// as the DUH-RPC spec requires. This is the default, as it avoids parsing the entire header.
+	NegotiateStrict Negotiation = iota
+	// NegotiateRFC7231 selects the codec with the highest quality value from all the media ranges in the
+	// `Accept` header as defined by RFC7231 Section 5.3.2, such that browsers and tools which send headers
+	// like `Accept: text/html, application/json;q=0.9` receive a reply they can accept.
+	NegotiateRFC7231
+)
+
+// Codecs is a set of codecs which are negotiated by content type. As the DUH-RPC spec requires
+// that services always support JSON, a set of Codecs always includes a JSON codec.
+type Codecs struct {
+	codecs      map[string]Codec
+	types       []string
+	negotiation Negotiation
+}
+
+// NewCodecs returns a new set of codecs, where each codec handles the content type returned by
+// Codec.ContentType(). If more than one codec handles the same content type, the last codec wins.
+// If none of the codecs handle ContentTypeJSON, JSONCodec{} is registered after the codecs provided.
+//
+//	codecs := duh.NewCodecs(duh.JSONCodec{}, duh.ProtoBufCodec{}, msgpack.Codec{})
+func NewCodecs(codecs ...Codec) *Codecs {
+	c := &Codecs{codecs: make(map[string]Codec, len(codecs)+1)}
+	for _, codec := range codecs {
+		c.Register(codec)
+	}
+	if _, ok := c.codecs[ContentTypeJSON]; !ok {
+		c.Register(JSONCodec{})
+	}
+	return c
+}
+
+// Register adds the codec to the set of codecs. Register is not safe to call while the Codecs are in use,
+// as such codecs should be registered before the service or client is started.
+func (c *Codecs) Register(codec Codec) {
+	ct := strings.ToLower(codec.ContentType())
+	if _, ok := c.codecs[ct]; !ok {
+		c.types = append(c.types, ct)
+	}
+	c.codecs[ct] = codec
+}
+
+// Lookup returns the codec for the content type provided. The content type is expected to have any mime type
+// parameters removed via TrimSuffix(). An empty content type or wild card (IE: `*/*`) returns the JSON codec.
+func (c *Codecs) Lookup(contentType string) (Codec, bool) {
+	ct := strings.TrimSpace(strings.ToLower(contentType))
+	switch ct {
+	case "", "*/*", "application/*":
+		ct = ContentTypeJSON
+	}
+	codec, ok := c.codecs[ct]
+	return codec, ok
+}
+
+// SetNegotiation sets the method used by Negotiate() to select a codec from the `Accept` header, the
+// default is NegotiateStrict. Like Register, SetNegotiation is not safe to call while the Codecs are in use.
+//
+//	codecs := duh.NewCodecs(duh.JSONCodec{}, duh.ProtoBufCodec{})
+//	codecs.SetNegotiation(duh.NegotiateRFC7231)
+//	router.Use(duh.WithCodecs(codecs))
+func (c *Codecs) SetNegotiation(n Negotiation) {
+	c.negotiation = n
+}
+
+// Negotiation returns the method used by Negotiate() to select a codec from the `Accept` header
+func (c *Codecs) Negotiation() Negotiation {
+	return c.negotiation
+}
+
+// Negotiate returns the codec which best matches the `Accept` header provided according to the
+// Negotiation of the codecs. An empty `Accept` header returns the JSON codec.
+func (c *Codecs) Negotiate(accept string) (Codec, bool) {
+	if c.negotiation != NegotiateRFC7231 {
+		// Ignore multiple mime types separated by comma ',' or mime type parameters separated by semicolon ';'
+		return c.Lookup(TrimSuffix(accept, ";,"))
+	}
+	if strings.TrimSpace(accept) == "" {
+		return c.Lookup("")
+	}
+
+	ranges := parseAccept(accept)
+	var best Codec
+	var bestQ float64
+	var bestSpecificity int
+	// Codecs are considered in the order they were registered, such that when the client
+	// has no preference, the preference of the server is used.
+	for _, ct := range c.types {
+		q, specificity := acceptQuality(ranges, ct)
+		if q <= 0 {
+			continue
+		}
+		if best == nil || q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = c.codecs[ct], q, specificity
+		}
+	}
+	return best, best != nil
+}
+
+// mediaRange is a single media range with its quality value from an `Accept` header
+type mediaRange struct {
+	mimeType string
+	q        float64
+}
+
+// parseAccept parses the media ranges of an `Accept` header as defined by RFC7231 Section 5.3.2, media
+// ranges with an invalid quality value are ignored.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mimeType, params, _ := strings.Cut(part, ";")
+		mimeType = strings.TrimSpace(strings.ToLower(mimeType))
+		if mimeType == "" {
+			continue
+		}
+
+		mr := mediaRange{mimeType: mimeType, q: 1}
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if !strings.EqualFold(k, "q") {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || q < 0 || q > 1 {
+				q = -1
+			}
+			mr.q = q
+			break
+		}
+		if mr.q >= 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality value of the most specific media range which matches the content
+// type, along with the specificity of the match. A specificity of zero indicates no match.
+func acceptQuality(ranges []mediaRange, contentType string) (float64, int) {
+	typ, _, _ := strings.Cut(contentType, "/")
+	var q float64
+	var specificity int
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.mimeType == contentType:
+			s = 3
+		case r.mimeType == typ+"/*":
+			s = 2
+		case r.mimeType == "*/*":
+			s = 1
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q, specificity
+}
+
+// ContentTypes returns the content types of the codecs in the order they were registered
+func (c *Codecs) ContentTypes() []string {
+	return append([]string(nil), c.types...)
+}
+
+type codecsKey struct{}
+
+// ContextWithCodecs returns a new context with the codecs provided, which are used by ReadRequest(),
+// Reply() and the stream functions to negotiate the content type of requests using the context. This
+// allows the supported content types to differ per method. See HandlerConfig.Codecs
+func ContextWithCodecs(ctx context.Context, c *Codecs) context.Context {
+	return context.WithValue(ctx, codecsKey{}, c)
+}
+
+// CodecsFromContext returns the codecs provided to ContextWithCodecs() or DefaultCodecs if none was provided
+func CodecsFromContext(ctx context.Context) *Codecs {
+	if c, ok := ctx.Value(codecsKey{}).(*Codecs); ok && c != nil {
+		return c
+	}
+	return DefaultCodecs
+}
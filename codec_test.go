@@ -0,0 +1,359 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/internal/test"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	json "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCodecs(t *testing.T) {
+	codecs := duh.NewCodecs(duh.JSONCodec{}, duh.ProtoBufCodec{})
+	assert.Equal(t, []string{duh.ContentTypeJSON, duh.ContentTypeProtoBuf}, codecs.ContentTypes())
+
+	for _, ct := range []string{"", "*/*", "application/*", "Application/JSON"} {
+		c, ok := codecs.Lookup(ct)
+		require.True(t, ok, ct)
+		assert.Equal(t, duh.ContentTypeJSON, c.ContentType())
+	}
+	_, ok := codecs.Lookup("application/bson")
+	assert.False(t, ok)
+
+	assert.Equal(t, duh.DefaultCodecs, duh.CodecsFromContext(context.Background()))
+	ctx := duh.ContextWithCodecs(context.Background(), codecs)
+	assert.Equal(t, codecs, duh.CodecsFromContext(ctx))
+}
+
+// failCodec replaces the JSON codec with a codec which always fails to marshal
+type failCodec struct{}
+
+func (failCodec) ContentType() string { return duh.ContentTypeJSON }
+func (failCodec) Marshal(proto.Message) ([]byte, error) {
+	return nil, errors.New("marshal failed")
+}
+func (failCodec) Unmarshal(b []byte, m proto.Message) error {
+	return json.Unmarshal(b, m)
+}
+
+func TestCodecsAlwaysIncludeJSON(t *testing.T) {
+	codecs := duh.NewCodecs(duh.ProtoBufCodec{})
+	assert.Equal(t, []string{duh.ContentTypeProtoBuf, duh.ContentTypeJSON}, codecs.ContentTypes())
+
+	// Replies which can not be negotiated or marshalled with the codecs fall back to JSON
+	reply := func(accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/test.reply", nil)
+		r.Header.Set("Accept", accept)
+		r = r.WithContext(duh.ContextWithCodecs(r.Context(), duh.NewCodecs(failCodec{})))
+		w := httptest.NewRecorder()
+		duh.Reply(w, r, duh.CodeOK, &test.ErrorsRequest{Case: "reply"})
+		return w
+	}
+
+	for _, tc := range []struct {
+		accept  string
+		code    int
+		message string
+	}{
+		{accept: "application/bson", code: duh.CodeClientContentError,
+			message: "Accept header 'application/bson' is invalid format"},
+		{accept: duh.ContentTypeJSON, code: duh.CodeInternalError, message: "marshal failed"},
+	} {
+		t.Run(tc.accept, func(t *testing.T) {
+			w := reply(tc.accept)
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, duh.ContentTypeJSON, w.Header().Get("Content-Type"))
+
+			var r v1.Reply
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &r))
+			assert.Equal(t, int32(tc.code), r.Code)
+			assert.Contains(t, r.Message, tc.message)
+		})
+	}
+}
+
+// retainCodec is a custom codec which retains the []byte provided to Unmarshal
+type retainCodec struct {
+	retained *[][]byte
+}
+
+func (retainCodec) ContentType() string                     { return "application/retain" }
+func (retainCodec) Marshal(m proto.Message) ([]byte, error) { return proto.Marshal(m) }
+func (c retainCodec) Unmarshal(b []byte, m proto.Message) error {
+	*c.retained = append(*c.retained, b)
+	return proto.Unmarshal(b, m)
+}
+
+func TestCodecsRetainBuffer(t *testing.T) {
+	var retained [][]byte
+	codecs := duh.NewCodecs(retainCodec{retained: &retained})
+
+	cases := []string{"first", "second", "third"}
+	for _, c := range cases {
+		b, err := proto.Marshal(&test.ErrorsRequest{Case: c})
+		require.NoError(t, err)
+		r := httptest.NewRequest(http.MethodPost, "/v1/test.retain", bytes.NewReader(b))
+		r.Header.Set("Content-Type", "application/retain")
+		r = r.WithContext(duh.ContextWithCodecs(r.Context(), codecs))
+
+		var req test.ErrorsRequest
+		require.NoError(t, duh.ReadRequest(r, &req, 0))
+		assert.Equal(t, c, req.Case)
+	}
+
+	// Codecs which do not implement PooledUnmarshaler are given a copy of the pooled buffer,
+	// such that reuse of the buffer by later requests does not modify the retained []byte
+	require.Len(t, retained, len(cases))
+	for i, c := range cases {
+		var req test.ErrorsRequest
+		require.NoError(t, proto.Unmarshal(retained[i], &req))
+		assert.Equal(t, c, req.Case)
+	}
+}
+
+func TestCodecsNegotiate(t *testing.T) {
+	codecs := duh.NewCodecs(duh.JSONCodec{}, duh.ProtoBufCodec{})
+
+	for _, tt := range []struct {
+		name    string
+		accept  string
+		strict  string
+		rfc7231 string
+	}{
+		{name: "empty", accept: "", strict: duh.ContentTypeJSON, rfc7231: duh.ContentTypeJSON},
+		{name: "single", accept: "application/protobuf", strict: duh.ContentTypeProtoBuf,
+			rfc7231: duh.ContentTypeProtoBuf},
+		{name: "browser", accept: "text/html, application/json;q=0.9", strict: "",
+			rfc7231: duh.ContentTypeJSON},
+		{name: "highest quality wins", accept: "application/json;q=0.5, application/protobuf;q=0.8",
+			strict: duh.ContentTypeJSON, rfc7231: duh.ContentTypeProtoBuf},
+		{name: "wildcard uses server preference", accept: "text/html, */*;q=0.8", strict: "",
+			rfc7231: duh.ContentTypeJSON},
+		{name: "specific range wins ties", accept: "*/*, application/protobuf", strict: duh.ContentTypeJSON,
+			rfc7231: duh.ContentTypeProtoBuf},
+		{name: "excluded by q=0", accept: "application/*, application/json;q=0", strict: duh.ContentTypeJSON,
+			rfc7231: duh.ContentTypeProtoBuf},
+		{name: "invalid quality ignored", accept: "application/protobuf;q=2, application/json;q=0.1",
+			strict: duh.ContentTypeProtoBuf, rfc7231: duh.ContentTypeJSON},
+		{name: "unsupported", accept: "text/html, image/png;q=0.9", strict: "", rfc7231: ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, n := range []struct {
+				negotiation duh.Negotiation
+				expected    string
+			}{
+				{negotiation: duh.NegotiateStrict, expected: tt.strict},
+				{negotiation: duh.NegotiateRFC7231, expected: tt.rfc7231},
+			} {
+				codecs.SetNegotiation(n.negotiation)
+				c, ok := codecs.Negotiate(tt.accept)
+				if n.expected == "" {
+					assert.False(t, ok)
+					continue
+				}
+				require.True(t, ok)
+				assert.Equal(t, n.expected, c.ContentType())
+			}
+		})
+	}
+
+	t.Run("reply", func(t *testing.T) {
+		codecs := duh.NewCodecs(duh.JSONCodec{}, duh.ProtoBufCodec{})
+		codecs.SetNegotiation(duh.NegotiateRFC7231)
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/test.reply", nil)
+		r = r.WithContext(duh.ContextWithCodecs(r.Context(), codecs))
+		r.Header.Set("Accept", "text/html,application/xhtml+xml,application/protobuf;q=0.9,*/*;q=0.8")
+		w := httptest.NewRecorder()
+		duh.Reply(w, r, duh.CodeOK, &test.ErrorsRequest{Case: "reply"})
+		assert.Equal(t, duh.CodeOK, w.Code)
+		assert.Equal(t, duh.ContentTypeProtoBuf, w.Header().Get("Content-Type"))
+
+		r.Header.Set("Accept", "text/html, image/png;q=0.9")
+		w = httptest.NewRecorder()
+		duh.Reply(w, r, duh.CodeOK, &test.ErrorsRequest{Case: "reply"})
+		assert.Equal(t, duh.CodeClientContentError, w.Code)
+		assert.Contains(t, w.Body.String(), "Accept header 'text/html, image/png;q=0.9' is invalid format")
+
+		// The default remains strict
+		r = httptest.NewRequest(http.MethodPost, "/v1/test.reply", nil)
+		r.Header.Set("Accept", "text/html, application/json;q=0.9")
+		w = httptest.NewRecorder()
+		duh.Reply(w, r, duh.CodeOK, &test.ErrorsRequest{Case: "reply"})
+		assert.Equal(t, duh.CodeClientContentError, w.Code)
+		assert.Contains(t, w.Body.String(), "Accept header 'text/html' is invalid format")
+	})
+}
+
+func TestCodecsPerMethod(t *testing.T) {
+	echo := func(ctx context.Context, req *test.ErrorsRequest, resp *test.ErrorsRequest) error {
+		resp.Case = req.Case
+		return nil
+	}
+
+	router := duh.NewRouter()
+	router.Handle("/v1/test.default", duh.NewHandler(echo, duh.HandlerConfig{}))
+	router.Handle("/v1/test.json-only", duh.NewHandler(echo,
+		duh.HandlerConfig{Codecs: duh.NewCodecs(duh.JSONCodec{})}))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	call := func(method string) error {
+		b, err := proto.Marshal(&test.ErrorsRequest{Case: "echo"})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, server.URL+method, bytes.NewReader(b))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", duh.ContentTypeProtoBuf)
+		req.Header.Set("Accept", duh.ContentTypeProtoBuf)
+
+		var resp test.ErrorsRequest
+		if err := duh.DefaultClient.Do(req, &resp); err != nil {
+			return err
+		}
+		assert.Equal(t, "echo", resp.Case)
+		return nil
+	}
+
+	require.NoError(t, call("/v1/test.default"))
+
+	err := call("/v1/test.json-only")
+	var e duh.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, duh.CodeClientContentError, e.Code())
+	assert.Contains(t, e.Message(), "'application/protobuf' is invalid format or unrecognized content type")
+}
+
+func TestJSONCodecOptions(t *testing.T) {
+	reply := func(ctx context.Context, req *test.ErrorsRequest, resp *v1.Reply) error {
+		resp.CodeText = req.Case
+		return nil
+	}
+
+	router := duh.NewRouter()
+	router.Use(duh.WithCodecs(duh.NewCodecs(duh.JSONCodec{
+		MarshalOptions:   json.MarshalOptions{EmitUnpopulated: true, UseProtoNames: true},
+		UnmarshalOptions: json.UnmarshalOptions{DiscardUnknown: true},
+	}, duh.ProtoBufCodec{})))
+	router.Handle("/v1/test.options", duh.NewHandler(reply, duh.HandlerConfig{}))
+	router.Handle("/v1/test.empty", duh.NewHandler(func(ctx context.Context, req *test.ErrorsRequest,
+		resp *test.ErrorsRequest) error {
+		return nil
+	}, duh.HandlerConfig{}))
+	router.Handle("/v1/test.strict", duh.NewHandler(reply, duh.HandlerConfig{Codecs: duh.DefaultCodecs}))
+	router.HandleFunc("/v1/test.newer", func(w http.ResponseWriter, r *http.Request) {
+		// Simulate a newer version of the service which has added a field
+		w.Header().Set("Content-Type", duh.ContentTypeJSON)
+		_, _ = w.Write([]byte(`{"case": "newer", "added": "field"}`))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(method, body string) *http.Response {
+		resp, err := http.Post(server.URL+method, duh.ContentTypeJSON, strings.NewReader(body))
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("server options", func(t *testing.T) {
+		resp := post("/v1/test.options", `{"case": "tolerated", "added": "field"}`)
+		defer func() { _ = resp.Body.Close() }()
+		require.Equal(t, duh.CodeOK, resp.StatusCode)
+
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		// v1.Reply is always marshalled with the default options
+		assert.JSONEq(t, `{"code_text": "tolerated"}`, string(b))
+
+		resp = post("/v1/test.empty", `{}`)
+		defer func() { _ = resp.Body.Close() }()
+		require.Equal(t, duh.CodeOK, resp.StatusCode)
+
+		b, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"case": ""}`, string(b))
+	})
+
+	t.Run("per method codecs take precedence", func(t *testing.T) {
+		resp := post("/v1/test.strict", `{"case": "rejected", "added": "field"}`)
+		defer func() { _ = resp.Body.Close() }()
+		assert.Equal(t, duh.CodeClientContentError, resp.StatusCode)
+	})
+
+	t.Run("client options", func(t *testing.T) {
+		newRequest := func() *http.Request {
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/test.newer", nil)
+			require.NoError(t, err)
+			return req
+		}
+
+		var resp test.ErrorsRequest
+		err := duh.DefaultClient.Do(newRequest(), &resp)
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientError, e.Code())
+
+		client := &duh.Client{
+			Client: http.DefaultClient,
+			Codecs: duh.NewCodecs(duh.JSONCodec{
+				UnmarshalOptions: json.UnmarshalOptions{DiscardUnknown: true},
+			}, duh.ProtoBufCodec{}),
+		}
+		require.NoError(t, client.Do(newRequest(), &resp))
+		assert.Equal(t, "newer", resp.Case)
+	})
+
+	t.Run("client requests", func(t *testing.T) {
+		var body []byte
+		capture := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ = io.ReadAll(r.Body)
+			duh.Reply(w, r, duh.CodeOK, &test.ErrorsRequest{})
+		}))
+		defer capture.Close()
+
+		client := &duh.Client{
+			Client: http.DefaultClient,
+			Codecs: duh.NewCodecs(duh.JSONCodec{
+				MarshalOptions: json.MarshalOptions{EmitUnpopulated: true},
+			}, duh.ProtoBufCodec{}),
+		}
+		req, err := client.NewRequest(context.Background(), capture.URL, duh.ContentTypeJSON, &test.ErrorsRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, duh.ContentTypeJSON, req.Header.Get("Content-Type"))
+		assert.Equal(t, duh.ContentTypeJSON, req.Header.Get("Accept"))
+
+		var resp test.ErrorsRequest
+		require.NoError(t, client.Do(req, &resp))
+		assert.JSONEq(t, `{"case": ""}`, string(body))
+
+		_, err = client.NewRequest(context.Background(), capture.URL, "application/xml", &test.ErrorsRequest{})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientError, e.Code())
+		assert.Contains(t, e.Error(), "unsupported content type 'application/xml'")
+	})
+}
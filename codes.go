@@ -0,0 +1,206 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+// codeError is a sentinel error which matches any Error with the same code via errors.Is()
+type codeError int
+
+func (c codeError) Error() string {
+	return CodeText(int(c))
+}
+
+// Sentinel errors for each DUH-RPC code which match any Error with the same code, for both errors created by
+// the service and errors returned by the Client. IE: errors.Is(err, duh.ErrNotFound) is true for any Error
+// with CodeNotFound. Use the Is<Code> predicates, IE: IsNotFound(), to also match Error implementations
+// from other packages.
+var (
+	ErrBadRequest         error = codeError(CodeBadRequest)
+	ErrUnauthorized       error = codeError(CodeUnauthorized)
+	ErrForbidden          error = codeError(CodeForbidden)
+	ErrNotFound           error = codeError(CodeNotFound)
+	ErrConflict           error = codeError(CodeConflict)
+	ErrTooManyRequests    error = codeError(CodeTooManyRequests)
+	ErrRequestFailed      error = codeError(CodeRequestFailed)
+	ErrRetryRequest       error = codeError(CodeRetryRequest)
+	ErrClientContentError error = codeError(CodeClientContentError)
+	ErrInternalError      error = codeError(CodeInternalError)
+	ErrNotImplemented     error = codeError(CodeNotImplemented)
+	ErrClientError        error = codeError(CodeClientError)
+	ErrTransportError     error = codeError(CodeTransportError)
+)
+
+// isCode returns true if the target is the sentinel error for the code provided
+func isCode(target error, code int) bool {
+	c, ok := target.(codeError)
+	return ok && int(c) == code
+}
+
+// hasCode returns true if err or any error it wraps is an Error with the code provided. Like errors.Is(),
+// the entire chain is searched, such that the predicates agree with errors.Is() when an Error wraps another
+// Error with a different code, IE: an internal error caused by a downstream not found.
+func hasCode(err error, code int) bool {
+	if err == nil {
+		return false
+	}
+	if e, ok := err.(Error); ok && e.Code() == code {
+		return true
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		return hasCode(u.Unwrap(), code)
+	case interface{ Unwrap() []error }:
+		for _, err := range u.Unwrap() {
+			if hasCode(err, code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The following constructors return an Error with the code of the constructor, such that service
+// implementations need not pass raw codes to NewServiceError(). The msg and err are handled in the
+// same way as NewServiceError().
+//
+//	if user == nil {
+//		return duh.NewNotFound(fmt.Sprintf("user '%s' not found", id), nil, nil)
+//	}
+
+// NewBadRequest returns an Error with CodeBadRequest for when a required parameter is missing or a value
+// provided is invalid
+func NewBadRequest(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeBadRequest, msg, err, details)
+}
+
+// NewUnauthorized returns an Error with CodeUnauthorized for when the caller is not authenticated
+func NewUnauthorized(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeUnauthorized, msg, err, details)
+}
+
+// NewForbidden returns an Error with CodeForbidden for when the caller is not authorized to perform the request
+func NewForbidden(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeForbidden, msg, err, details)
+}
+
+// NewNotFound returns an Error with CodeNotFound for when the thing requested was not found
+func NewNotFound(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeNotFound, msg, err, details)
+}
+
+// NewConflict returns an Error with CodeConflict for when the request conflicts with another request
+func NewConflict(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeConflict, msg, err, details)
+}
+
+// NewTooManyRequests returns an Error with CodeTooManyRequests for when the caller exceeded a rate limit, see
+// RateLimit.Details()
+func NewTooManyRequests(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeTooManyRequests, msg, err, details)
+}
+
+// NewRequestFailed returns an Error with CodeRequestFailed for when the request is valid but failed, and no
+// other code makes sense
+func NewRequestFailed(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeRequestFailed, msg, err, details)
+}
+
+// NewRetryRequest returns an Error with CodeRetryRequest for when the request is valid but the client should try
+// again
+func NewRetryRequest(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeRetryRequest, msg, err, details)
+}
+
+// NewClientContentError returns an Error with CodeClientContentError for when the content provided does not
+// follow the DUH-RPC spec
+func NewClientContentError(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeClientContentError, msg, err, details)
+}
+
+// NewInternalError returns an Error with CodeInternalError for when the service encountered an unexpected condition
+func NewInternalError(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeInternalError, msg, err, details)
+}
+
+// NewNotImplemented returns an Error with CodeNotImplemented for when the method requested is not implemented
+func NewNotImplemented(msg string, err error, details map[string]string) error {
+	return NewServiceError(CodeNotImplemented, msg, err, details)
+}
+
+// IsBadRequest returns true if the error or any error it wraps is an Error with CodeBadRequest
+func IsBadRequest(err error) bool {
+	return hasCode(err, CodeBadRequest)
+}
+
+// IsUnauthorized returns true if the error or any error it wraps is an Error with CodeUnauthorized
+func IsUnauthorized(err error) bool {
+	return hasCode(err, CodeUnauthorized)
+}
+
+// IsForbidden returns true if the error or any error it wraps is an Error with CodeForbidden
+func IsForbidden(err error) bool {
+	return hasCode(err, CodeForbidden)
+}
+
+// IsNotFound returns true if the error or any error it wraps is an Error with CodeNotFound
+func IsNotFound(err error) bool {
+	return hasCode(err, CodeNotFound)
+}
+
+// IsConflict returns true if the error or any error it wraps is an Error with CodeConflict
+func IsConflict(err error) bool {
+	return hasCode(err, CodeConflict)
+}
+
+// IsTooManyRequests returns true if the error or any error it wraps is an Error with CodeTooManyRequests
+func IsTooManyRequests(err error) bool {
+	return hasCode(err, CodeTooManyRequests)
+}
+
+// IsRequestFailed returns true if the error or any error it wraps is an Error with CodeRequestFailed
+func IsRequestFailed(err error) bool {
+	return hasCode(err, CodeRequestFailed)
+}
+
+// IsRetryRequest returns true if the error or any error it wraps is an Error with CodeRetryRequest
+func IsRetryRequest(err error) bool {
+	return hasCode(err, CodeRetryRequest)
+}
+
+// IsClientContentError returns true if the error or any error it wraps is an Error with CodeClientContentError
+func IsClientContentError(err error) bool {
+	return hasCode(err, CodeClientContentError)
+}
+
+// IsInternalError returns true if the error or any error it wraps is an Error with CodeInternalError
+func IsInternalError(err error) bool {
+	return hasCode(err, CodeInternalError)
+}
+
+// IsNotImplemented returns true if the error or any error it wraps is an Error with CodeNotImplemented
+func IsNotImplemented(err error) bool {
+	return hasCode(err, CodeNotImplemented)
+}
+
+// IsClientError returns true if the error or any error it wraps is an Error with CodeClientError, which
+// indicates the Client failed before or after sending the request, not that the error is a *ClientError.
+func IsClientError(err error) bool {
+	return hasCode(err, CodeClientError)
+}
+
+// IsTransportError returns true if the error or any error it wraps is an Error with CodeTransportError,
+// which indicates the request or reply could not be sent or received.
+func IsTransportError(err error) bool {
+	return hasCode(err, CodeTransportError)
+}
@@ -0,0 +1,387 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+
+	// DefaultCompressMinSize is the minimum size of a body before it is compressed, bodies smaller
+	// than this are not worth the CPU cost of compression.
+	DefaultCompressMinSize = Kibibyte
+
+	// zstdMaxWindow is the maximum window size a zstd decoder will allocate, as recommended
+	// by RFC8878 for HTTP content encoding.
+	zstdMaxWindow = 8 * Mebibyte
+)
+
+// Encoder compresses and decompresses bodies for a single `Content-Encoding`
+type Encoder interface {
+	// Encoding returns the name of the content encoding, IE: `gzip`
+	Encoding() string
+	// NewWriter returns a writer which compresses everything written to it into w
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader which decompresses the contents of r
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// GzipEncoder is an Encoder for the `gzip` content encoding
+type GzipEncoder struct {
+	// Level is the gzip compression level, if zero gzip.DefaultCompression is used
+	Level int
+}
+
+func (e GzipEncoder) Encoding() string {
+	return EncodingGzip
+}
+
+// NewWriter returns a gzip writer from a pool, the writer is returned to the pool when it is closed
+func (e GzipEncoder) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	level := e.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, fmt.Errorf("gzip: invalid compression level: %d", level)
+	}
+
+	pool := &gzipWriters[level-gzip.HuffmanOnly]
+	if gw, ok := pool.Get().(*gzip.Writer); ok {
+		gw.Reset(w)
+		return &pooledWriter{WriteCloser: gw, put: pool.Put}, nil
+	}
+	gw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledWriter{WriteCloser: gw, put: pool.Put}, nil
+}
+
+func (e GzipEncoder) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// ZstdEncoder is an Encoder for the `zstd` content encoding
+type ZstdEncoder struct {
+	// Level is the zstd compression level, if zero zstd.SpeedDefault is used
+	Level zstd.EncoderLevel
+}
+
+func (e ZstdEncoder) Encoding() string {
+	return EncodingZstd
+}
+
+// NewWriter returns a zstd writer from a pool, the writer is returned to the pool when it is closed
+func (e ZstdEncoder) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	level := e.Level
+	if level == 0 {
+		level = zstd.SpeedDefault
+	}
+	if level < zstd.SpeedFastest || level > zstd.SpeedBestCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", level)
+	}
+
+	pool := &zstdWriters[level]
+	if zw, ok := pool.Get().(*zstd.Encoder); ok {
+		zw.Reset(w)
+		return &pooledWriter{WriteCloser: zw, put: pool.Put}, nil
+	}
+	zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(level),
+		zstd.WithWindowSize(zstdMaxWindow))
+	if err != nil {
+		return nil, err
+	}
+	return &pooledWriter{WriteCloser: zw, put: pool.Put}, nil
+}
+
+func (e ZstdEncoder) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+var (
+	// gzipWriters and zstdWriters pool writers by compression level, as each new writer allocates
+	// hundreds of kilobytes of compression state which would otherwise be allocated for every reply.
+	gzipWriters [gzip.BestCompression - gzip.HuffmanOnly + 1]sync.Pool
+	zstdWriters [zstd.SpeedBestCompression + 1]sync.Pool
+)
+
+// pooledWriter returns the underlying writer to the pool once it is closed
+type pooledWriter struct {
+	io.WriteCloser
+	put func(any)
+}
+
+func (w *pooledWriter) Close() error {
+	if w.WriteCloser == nil {
+		return nil
+	}
+	err := w.WriteCloser.Close()
+	if err == nil {
+		w.put(w.WriteCloser)
+	}
+	w.WriteCloser = nil
+	return err
+}
+
+// DefaultCompression is the Compression used by ReadRequest(), Reply() and Client if no other Compression
+// is provided. It prefers zstd over gzip and does not compress request bodies.
+var DefaultCompression = &Compression{
+	Encoders: []Encoder{ZstdEncoder{}, GzipEncoder{}},
+	MinSize:  DefaultCompressMinSize,
+}
+
+// Compression configures the content encodings used to compress and decompress request and reply bodies.
+//
+// Servers decompress request bodies with a supported `Content-Encoding`, and compress replies larger than
+// MinSize using the most preferred encoding the client lists in the `Accept-Encoding` header. Clients list
+// the supported encodings in the `Accept-Encoding` header and decompress the replies, and optionally compress
+// request bodies using RequestEncoding.
+//
+// Decompressed request bodies are capped by the read limit of the method, such that a decompression bomb
+// results in ErrDataLimitExceeded.
+type Compression struct {
+	// Encoders are the supported encodings in order of preference. If empty, compression is disabled.
+	Encoders []Encoder
+	// MinSize is the minimum size in bytes of a body before it is compressed.
+	MinSize int
+	// RequestEncoding is the encoding used by the Client to compress request bodies larger than MinSize.
+	// It must be the encoding of one of the Encoders. If empty, request bodies are not compressed.
+	// Since the client cannot know in advance what the server supports, it should only be set when
+	// the server is known to support the encoding.
+	RequestEncoding string
+}
+
+// Lookup returns the Encoder for the encoding provided
+func (c *Compression) Lookup(encoding string) (Encoder, bool) {
+	encoding = strings.TrimSpace(strings.ToLower(encoding))
+	for _, e := range c.Encoders {
+		if e.Encoding() == encoding {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// AcceptEncoding returns the value of the `Accept-Encoding` header which lists the supported encodings
+func (c *Compression) AcceptEncoding() string {
+	encodings := make([]string, len(c.Encoders))
+	for i, e := range c.Encoders {
+		encodings[i] = e.Encoding()
+	}
+	return strings.Join(encodings, ", ")
+}
+
+// negotiate returns the most preferred Encoder which is acceptable according to the `Accept-Encoding`
+// header provided. Returns false if none of the Encoders are acceptable.
+func (c *Compression) negotiate(acceptEncoding string) (Encoder, bool) {
+	if acceptEncoding == "" {
+		return nil, false
+	}
+
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(strings.ToLower(name))
+		accepted[name] = !isQZero(params)
+	}
+
+	for _, e := range c.Encoders {
+		if ok, found := accepted[e.Encoding()]; found {
+			if ok {
+				return e, true
+			}
+			continue
+		}
+		if accepted["*"] {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// isQZero returns true if the parameters provided include a quality value of zero, IE: `q=0`
+func isQZero(params string) bool {
+	for _, p := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if strings.EqualFold(k, "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return err == nil && q == 0
+		}
+	}
+	return false
+}
+
+// compress appends the body compressed with the Encoder provided to dst and returns the extended buffer
+func compress(e Encoder, dst, body []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, err := e.NewWriter(buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress returns a reader which decompresses the body according to the content encoding provided.
+// If the content encoding is empty or `identity` the body is returned un-modified. Errors returned by
+// the reader which are caused by invalid compressed content implement duh.Error with the code provided,
+// while errors from reading the body are returned un-modified.
+func decompress(c *Compression, encoding string, body io.ReadCloser, code int) (io.ReadCloser, error) {
+	encoding = strings.TrimSpace(strings.ToLower(encoding))
+	if encoding == "" || encoding == "identity" {
+		return body, nil
+	}
+
+	e, ok := c.Lookup(encoding)
+	if !ok {
+		return nil, NewServiceError(code,
+			fmt.Sprintf("has unsupported Content-Encoding '%s', only [%s] are supported",
+				encoding, c.AcceptEncoding()), nil, nil)
+	}
+
+	src := &errReader{r: body, encoding: encoding, code: code}
+	r, err := e.NewReader(src)
+	if err != nil {
+		// An empty body is not valid compressed content
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, src.wrap(err)
+	}
+	return &decompressReader{r: r, src: src}, nil
+}
+
+// errReader records any error returned by the underlying reader, such that we can tell
+// if an error returned by a decompressor was caused by the reader or by invalid content.
+type errReader struct {
+	r        io.ReadCloser
+	encoding string
+	code     int
+	err      error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		e.err = err
+	}
+	return n, err
+}
+
+// wrap returns the error un-modified if it was caused by the underlying reader, else it wraps the error
+// as a duh.Error as the compressed content is invalid.
+func (e *errReader) wrap(err error) error {
+	if err == nil || errors.Is(err, io.EOF) || e.err != nil {
+		return err
+	}
+	return NewServiceError(e.code, fmt.Sprintf("has invalid %s content", e.encoding), err, nil)
+}
+
+type decompressReader struct {
+	r   io.ReadCloser
+	src *errReader
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	return n, d.src.wrap(err)
+}
+
+func (d *decompressReader) Close() error {
+	_ = d.r.Close()
+	return d.src.r.Close()
+}
+
+type compressionKey struct{}
+
+// ContextWithCompression returns a new context with the Compression provided, which is used by
+// ReadRequest() and Reply() to decompress requests and compress replies. See WithCompression
+func ContextWithCompression(ctx context.Context, c *Compression) context.Context {
+	return context.WithValue(ctx, compressionKey{}, c)
+}
+
+// CompressionFromContext returns the Compression provided to ContextWithCompression() or
+// DefaultCompression if none was provided
+func CompressionFromContext(ctx context.Context) *Compression {
+	if c, ok := ctx.Value(compressionKey{}).(*Compression); ok && c != nil {
+		return c
+	}
+	return DefaultCompression
+}
+
+// setCompressedBody compresses the request body with the RequestEncoding if the body is larger than MinSize.
+// The request is cloned before it is modified, such that the request provided by the caller is unchanged.
+func (c *Compression) setCompressedBody(req *http.Request) (*http.Request, error) {
+	if c.RequestEncoding == "" || req.Body == nil || req.Body == http.NoBody || req.GetBody == nil ||
+		req.Header.Get("Content-Encoding") != "" || req.ContentLength < int64(c.MinSize) {
+		return req, nil
+	}
+
+	e, ok := c.Lookup(c.RequestEncoding)
+	if !ok {
+		return nil, fmt.Errorf("RequestEncoding '%s' is not one of the Compression.Encoders", c.RequestEncoding)
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(body)
+	_ = body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err = compress(e, nil, b)
+	if err != nil {
+		return nil, err
+	}
+
+	// The compressed body replaces the original, which would otherwise never be closed
+	_ = req.Body.Close()
+
+	r := req.Clone(req.Context())
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	r.ContentLength = int64(len(b))
+	r.Header.Set("Content-Encoding", e.Encoding())
+	return r, nil
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/internal/test"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	json "google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestCompression(t *testing.T) {
+	echo := func(ctx context.Context, req *test.ErrorsRequest, resp *test.ErrorsRequest) error {
+		resp.Case = req.Case
+		return nil
+	}
+	router := duh.NewRouter()
+	router.Handle("/v1/test.echo", duh.NewHandler(echo, duh.HandlerConfig{ReadLimit: duh.MegaByte}))
+	var lastEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastEncoding = r.Header.Get("Content-Encoding")
+		router.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	large := strings.Repeat("compress me ", 1000)
+
+	post := func(body []byte, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/test.echo", bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", duh.ContentTypeJSON)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		// Use a transport which does not transparently decompress
+		resp, err := (&http.Transport{DisableCompression: true}).RoundTrip(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	payload := func(s string) []byte {
+		b, err := json.Marshal(&test.ErrorsRequest{Case: s})
+		require.NoError(t, err)
+		return b
+	}
+
+	t.Run("reply", func(t *testing.T) {
+		for _, tc := range []struct {
+			name           string
+			acceptEncoding string
+			body           string
+			expected       string
+		}{
+			{name: "gzip", acceptEncoding: "gzip", body: large, expected: duh.EncodingGzip},
+			{name: "prefers zstd", acceptEncoding: "gzip, deflate, br, zstd", body: large, expected: duh.EncodingZstd},
+			{name: "q zero", acceptEncoding: "zstd;q=0, gzip;q=0.5", body: large, expected: duh.EncodingGzip},
+			{name: "wild card", acceptEncoding: "*", body: large, expected: duh.EncodingZstd},
+			{name: "unsupported", acceptEncoding: "br", body: large, expected: ""},
+			{name: "below min size", acceptEncoding: "gzip", body: "small", expected: ""},
+			{name: "no accept encoding", body: large, expected: ""},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				resp := post(payload(tc.body), map[string]string{"Accept-Encoding": tc.acceptEncoding})
+				defer func() { _ = resp.Body.Close() }()
+				require.Equal(t, duh.CodeOK, resp.StatusCode)
+				assert.Equal(t, tc.expected, resp.Header.Get("Content-Encoding"))
+				assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+
+				var r io.Reader = resp.Body
+				switch tc.expected {
+				case duh.EncodingGzip:
+					r, _ = gzip.NewReader(resp.Body)
+				case duh.EncodingZstd:
+					r, _ = zstd.NewReader(resp.Body)
+				}
+				b, err := io.ReadAll(r)
+				require.NoError(t, err)
+
+				var m test.ErrorsRequest
+				require.NoError(t, json.Unmarshal(b, &m))
+				assert.Equal(t, tc.body, m.Case)
+			})
+		}
+	})
+
+	t.Run("decompression bomb", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, _ = w.Write([]byte(`{"case": "`))
+		_, _ = w.Write(bytes.Repeat([]byte("a"), 10*duh.MegaByte))
+		_, _ = w.Write([]byte(`"}`))
+		require.NoError(t, w.Close())
+		require.Less(t, buf.Len(), 20*duh.Kilobyte)
+
+		resp := post(buf.Bytes(), map[string]string{"Content-Encoding": "gzip"})
+		defer func() { _ = resp.Body.Close() }()
+		assert.Equal(t, duh.CodeBadRequest, resp.StatusCode)
+		b, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(b), "request body exceeds 1.0MB limit")
+	})
+
+	t.Run("invalid content", func(t *testing.T) {
+		resp := post([]byte("not gzip"), map[string]string{"Content-Encoding": "gzip"})
+		defer func() { _ = resp.Body.Close() }()
+		assert.Equal(t, duh.CodeClientContentError, resp.StatusCode)
+		b, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(b), "request body has invalid gzip content")
+	})
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		resp := post(payload("hello"), map[string]string{"Content-Encoding": "br"})
+		defer func() { _ = resp.Body.Close() }()
+		assert.Equal(t, duh.CodeClientContentError, resp.StatusCode)
+		b, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(b), "request body has unsupported Content-Encoding 'br'")
+	})
+
+	t.Run("client", func(t *testing.T) {
+		for _, encoding := range []string{"", duh.EncodingGzip, duh.EncodingZstd} {
+			t.Run("request encoding "+encoding, func(t *testing.T) {
+				client := &duh.Client{
+					Client: http.DefaultClient,
+					Compression: &duh.Compression{
+						Encoders:        []duh.Encoder{duh.ZstdEncoder{}, duh.GzipEncoder{}},
+						MinSize:         duh.DefaultCompressMinSize,
+						RequestEncoding: encoding,
+					},
+				}
+				req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/test.echo", bytes.NewReader(payload(large)))
+				require.NoError(t, err)
+				req.Header.Set("Content-Type", duh.ContentTypeJSON)
+
+				var resp test.ErrorsRequest
+				require.NoError(t, client.Do(req, &resp))
+				assert.Equal(t, large, resp.Case)
+				assert.Equal(t, encoding, lastEncoding)
+				assert.Equal(t, "", req.Header.Get("Content-Encoding"), "caller request should not be modified")
+			})
+		}
+	})
+
+	t.Run("client closes the original body", func(t *testing.T) {
+		client := &duh.Client{
+			Client:      http.DefaultClient,
+			Compression: &duh.Compression{Encoders: []duh.Encoder{duh.GzipEncoder{}}, RequestEncoding: duh.EncodingGzip},
+		}
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/test.echo", bytes.NewReader(payload(large)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", duh.ContentTypeJSON)
+		body := &closeBody{Reader: bytes.NewReader(payload(large))}
+		req.Body = body
+
+		var resp test.ErrorsRequest
+		require.NoError(t, client.Do(req, &resp))
+		assert.Equal(t, large, resp.Case)
+		assert.True(t, body.closed)
+	})
+
+	t.Run("client unknown request encoding", func(t *testing.T) {
+		client := &duh.Client{
+			Client:      http.DefaultClient,
+			Compression: &duh.Compression{Encoders: []duh.Encoder{duh.GzipEncoder{}}, RequestEncoding: "br"},
+		}
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/test.echo", bytes.NewReader(payload(large)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", duh.ContentTypeJSON)
+
+		err = client.Do(req, &test.ErrorsRequest{})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientError, e.Code())
+		assert.Contains(t, e.Error(), "RequestEncoding 'br' is not one of the Compression.Encoders")
+		assert.Equal(t, server.URL+"/v1/test.echo", e.Details()[duh.DetailsHttpUrl])
+		assert.Equal(t, http.MethodPost, e.Details()[duh.DetailsHttpMethod])
+	})
+
+	t.Run("client invalid response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", duh.ContentTypeJSON)
+			w.Header().Set("Content-Encoding", duh.EncodingGzip)
+			_, _ = w.Write([]byte("not gzip"))
+		}))
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/test.echo", nil)
+		require.NoError(t, err)
+		err = duh.DefaultClient.Do(req, &test.ErrorsRequest{})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientError, e.Code())
+		assert.Contains(t, e.Error(), "response body has invalid gzip content")
+	})
+
+	t.Run("encoder writers are reused", func(t *testing.T) {
+		for _, e := range []duh.Encoder{duh.GzipEncoder{}, duh.ZstdEncoder{}} {
+			for _, payload := range []string{"first payload", "second payload"} {
+				var buf bytes.Buffer
+				w, err := e.NewWriter(&buf)
+				require.NoError(t, err)
+				_, err = w.Write([]byte(payload))
+				require.NoError(t, err)
+				require.NoError(t, w.Close())
+				// Closing twice must not return the writer to the pool twice
+				require.NoError(t, w.Close())
+
+				r, err := e.NewReader(&buf)
+				require.NoError(t, err)
+				b, err := io.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, payload, string(b), e.Encoding())
+			}
+		}
+	})
+
+	t.Run("invalid encoder level", func(t *testing.T) {
+		_, err := duh.GzipEncoder{Level: 42}.NewWriter(io.Discard)
+		assert.EqualError(t, err, "gzip: invalid compression level: 42")
+		_, err = duh.ZstdEncoder{Level: 42}.NewWriter(io.Discard)
+		assert.EqualError(t, err, "zstd: invalid compression level: 42")
+	})
+}
+
+// closeBody records if the body was closed
+type closeBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeBody) Close() error {
+	b.closed = true
+	return nil
+}
@@ -15,17 +15,15 @@ limitations under the License.
 package demo
 
 import (
-	"bytes"
 	"context"
-	"fmt"
 	"net/http"
 
 	"github.com/duh-rpc/duh-go"
-	json "google.golang.org/protobuf/encoding/protojson"
-	"google.golang.org/protobuf/proto"
 )
 
 // Client is a simple client that calls the Service
+//
+// Deprecated: Use the generated SayClient and RenderClient, IE: NewSayClient()
 type Client struct {
 	*duh.Client
 	endpoint string
@@ -36,6 +34,9 @@ type ClientConfig struct {
 	Client   *http.Client
 }
 
+// NewClient returns a new Client which calls the Service at the endpoint provided
+//
+// Deprecated: Use the generated NewSayClient() and NewRenderClient()
 func NewClient(conf ClientConfig) *Client {
 	if conf.Client == nil {
 		conf.Client = &http.Client{Transport: http.DefaultTransport}
@@ -50,38 +51,11 @@ func NewClient(conf ClientConfig) *Client {
 
 // SayHello sends a name to the service using JSON, and the service says hello.
 func (c *Client) SayHello(ctx context.Context, req *SayHelloRequest, resp *SayHelloResponse) error {
-	payload, err := json.Marshal(req)
-	if err != nil {
-		return duh.NewClientError("while marshaling request payload: %w", err, nil)
-	}
-
-	r, err := http.NewRequestWithContext(ctx, http.MethodPost,
-		fmt.Sprintf("%s/%s", c.endpoint, "v1/say.hello"), bytes.NewReader(payload))
-	if err != nil {
-		return duh.NewClientError("", err, nil)
-	}
-
-	// Tell the server what kind of serialization we are sending it.
-	r.Header.Set("Content-Type", duh.ContentTypeJSON)
-
-	// Do() will handle content negotiation, error handling, and un-marshal the response
-	return c.Do(r, resp)
+	return NewSayClient(c.Client, c.endpoint).WithContentType(duh.ContentTypeJSON).Hello(ctx, req, resp)
 }
 
 // RenderPixel sends a request to the service which calculates the pixel color of a Mandelbrot
 // fractal at the given point in the image.
 func (c *Client) RenderPixel(ctx context.Context, req *RenderPixelRequest, resp *RenderPixelResponse) error {
-	payload, err := proto.Marshal(req)
-	if err != nil {
-		return duh.NewClientError("while marshaling request payload: %w", err, nil)
-	}
-
-	r, err := http.NewRequestWithContext(ctx, http.MethodPost,
-		fmt.Sprintf("%s/%s", c.endpoint, "v1/render.pixel"), bytes.NewReader(payload))
-	if err != nil {
-		return duh.NewClientError("", err, nil)
-	}
-
-	r.Header.Set("Content-Type", duh.ContentTypeProtoBuf)
-	return c.Do(r, resp)
+	return NewRenderClient(c.Client, c.endpoint).Pixel(ctx, req, resp)
 }
@@ -274,10 +274,19 @@ var file_demo_demo_proto_rawDesc = []byte{
 	0x12, 0x0c, 0x0a, 0x01, 0x6a, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x01, 0x6a, 0x22, 0x29,
 	0x0a, 0x13, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x50, 0x69, 0x78, 0x65, 0x6c, 0x52, 0x65, 0x73,
 	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x67, 0x72, 0x61, 0x79, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x03, 0x52, 0x04, 0x67, 0x72, 0x61, 0x79, 0x42, 0x20, 0x5a, 0x1e, 0x67, 0x69, 0x74,
-	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x75, 0x68, 0x2d, 0x72, 0x70, 0x63, 0x2f,
-	0x64, 0x75, 0x68, 0x2d, 0x67, 0x6f, 0x2f, 0x64, 0x65, 0x6d, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x33,
+	0x01, 0x28, 0x03, 0x52, 0x04, 0x67, 0x72, 0x61, 0x79, 0x32, 0x41, 0x0a, 0x03, 0x53, 0x61, 0x79,
+	0x12, 0x3a, 0x0a, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x17, 0x2e, 0x64, 0x75, 0x68, 0x2e,
+	0x76, 0x31, 0x2e, 0x53, 0x61, 0x79, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x18, 0x2e, 0x64, 0x75, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x61, 0x79, 0x48,
+	0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0x4a, 0x0a, 0x06,
+	0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x40, 0x0a, 0x05, 0x50, 0x69, 0x78, 0x65, 0x6c, 0x12,
+	0x1a, 0x2e, 0x64, 0x75, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x50,
+	0x69, 0x78, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x64, 0x75,
+	0x68, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x50, 0x69, 0x78, 0x65, 0x6c,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x20, 0x5a, 0x1e, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x75, 0x68, 0x2d, 0x72, 0x70, 0x63, 0x2f, 0x64,
+	0x75, 0x68, 0x2d, 0x67, 0x6f, 0x2f, 0x64, 0x65, 0x6d, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
 }
 
 var (
@@ -300,8 +309,12 @@ var file_demo_demo_proto_goTypes = []interface{}{
 	(*RenderPixelResponse)(nil), // 3: duh.v1.RenderPixelResponse
 }
 var file_demo_demo_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
+	0, // 0: duh.v1.Say.Hello:input_type -> duh.v1.SayHelloRequest
+	2, // 1: duh.v1.Render.Pixel:input_type -> duh.v1.RenderPixelRequest
+	1, // 2: duh.v1.Say.Hello:output_type -> duh.v1.SayHelloResponse
+	3, // 3: duh.v1.Render.Pixel:output_type -> duh.v1.RenderPixelResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -370,7 +383,7 @@ func file_demo_demo_proto_init() {
 			NumEnums:      0,
 			NumMessages:   4,
 			NumExtensions: 0,
-			NumServices:   0,
+			NumServices:   2,
 		},
 		GoTypes:           file_demo_demo_proto_goTypes,
 		DependencyIndexes: file_demo_demo_proto_depIdxs,
@@ -15,67 +15,39 @@ limitations under the License.
 package demo
 
 import (
-	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/ratelimit"
 )
 
-type Handler struct {
-	Service *Service
-}
-
-func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+// NewHandler returns an http.Handler which routes DUH-RPC method calls to the Service
+func NewHandler(s *Service) http.Handler {
 
-	// TODO: Middleware
 	// TODO: Authentication
 	// TODO: Authorization
-	// TODO: Max Read Limit Middleware
-	// TODO: Rate Limit Middleware
 
-	if r.Method != http.MethodPost {
-		duh.ReplyWithCode(w, r, duh.CodeBadRequest, nil,
-			fmt.Sprintf("http method '%s' not allowed; only POST", r.Method))
-		return
-	}
+	conf := duh.HandlerConfig{ReadLimit: 5 * duh.MegaByte}
 
-	// No need for fancy routers, a switch case is performant and simple.
-	switch r.URL.Path {
-	case "/v1/say.hello":
-		h.handleSayHello(w, r)
-		return
-	case "/v1/render.pixel":
-		h.handleRenderPixel(w, r)
-		return
-	}
-	duh.ReplyWithCode(w, r, duh.CodeNotImplemented, nil, "no such method; "+r.URL.Path)
-}
+	// No need for fancy routers, the duh.Router does an exact match on the method path
+	// which is performant and simple. The Register functions are generated by protoc-gen-duh
+	router := duh.NewRouter()
 
-func (h *Handler) handleSayHello(w http.ResponseWriter, r *http.Request) {
-	// TODO: Verify the authenticated user can access this endpoint
-	var req SayHelloRequest
-	if err := duh.ReadRequest(r, &req, 5*duh.MegaByte); err != nil {
-		duh.ReplyError(w, r, err)
-		return
-	}
-	var resp SayHelloResponse
-	if err := h.Service.SayHello(r.Context(), &req, &resp); err != nil {
-		duh.ReplyError(w, r, err)
-		return
-	}
-	duh.Reply(w, r, duh.CodeOK, &resp)
-}
+	// Log unexpected errors and reply with a generic message, such that internal details are never sent to clients
+	router.Use(duh.WithRedaction(&duh.Redaction{Log: slog.Default()}))
+
+	// Limit each client to 1,000 requests per second for each method
+	router.Use(ratelimit.Middleware(ratelimit.Config{
+		Key:      ratelimit.Compose(ratelimit.ByClientIP, ratelimit.ByMethod),
+		Duration: time.Second,
+		Limit:    1_000,
+	}))
+	RegisterSayServer(router, s, conf)
+	RegisterRenderServer(router, s, conf)
 
-func (h *Handler) handleRenderPixel(w http.ResponseWriter, r *http.Request) {
-	var req RenderPixelRequest
-	if err := duh.ReadRequest(r, &req, 5*duh.MegaByte); err != nil {
-		duh.ReplyError(w, r, err)
-		return
-	}
-	var resp RenderPixelResponse
-	if err := h.Service.RenderPixel(r.Context(), &req, &resp); err != nil {
-		duh.ReplyError(w, r, err)
-		return
-	}
-	duh.Reply(w, r, duh.CodeOK, &resp)
+	// Allow tools like the `duh` CLI to discover the methods of the service
+	router.Handle(duh.MethodsMethod, duh.NewMethodsHandler(router))
+	return router
 }
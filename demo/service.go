@@ -16,6 +16,7 @@ package demo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/duh-rpc/duh-go"
@@ -28,29 +29,35 @@ func NewService() *Service {
 	return &Service{}
 }
 
-// TODO: Define abstracted errors here
-// ErrInternal
-// ErrBadRequest
-// ErrRequestFailed
+var (
+	// ErrNameRequired is the cause of the error returned when SayHelloRequest.Name is empty
+	ErrNameRequired = errors.New("name is empty")
+	// ErrNameNotCapitalized is the cause of the error returned when SayHelloRequest.Name is not capitalized
+	ErrNameNotCapitalized = errors.New("name is not capitalized")
+)
 
 // Service is an example of a production ready service implementation
 type Service struct{}
 
-func (h *Service) SayHello(ctx context.Context, req *SayHelloRequest, resp *SayHelloResponse) error {
+var _ SayServer = (*Service)(nil)
+var _ RenderServer = (*Service)(nil)
+
+// Hello says hello to the name provided
+func (h *Service) Hello(ctx context.Context, req *SayHelloRequest, resp *SayHelloResponse) error {
 	if req.Name == "" {
-		// TODO: This needs to be an internal error, and the caller should instead convert to a NewServiceError
-		return duh.NewServiceError(duh.CodeBadRequest, "'name' is required and cannot be empty", nil, nil)
+		// The message is sent to the client, while the cause is available to the caller via errors.Is()
+		return duh.NewBadRequest("'name' is required and cannot be empty", ErrNameRequired, nil)
 	}
 	if cases.Title(language.English).String(req.Name) != req.Name {
-		return duh.NewServiceError(duh.CodeBadRequest, "'name' must be capitalized", nil, nil)
+		return duh.NewBadRequest("'name' must be capitalized", ErrNameNotCapitalized, nil)
 	}
 	resp.Message = fmt.Sprintf("Hello, %s", req.Name)
 	return nil
 }
 
-// RenderPixel returns the color of a Mandelbrot fractal at the given point in the image.
+// Pixel returns the color of a Mandelbrot fractal at the given point in the image.
 // Code copied from Francesc Campoy's Golang Tracer example (https://tinyurl.com/ery6mfz8)
-func (h *Service) RenderPixel(ctx context.Context, req *RenderPixelRequest, resp *RenderPixelResponse) error {
+func (h *Service) Pixel(ctx context.Context, req *RenderPixelRequest, resp *RenderPixelResponse) error {
 	xi := norm(req.I, req.Width, -1.0, 2)
 	yi := norm(req.J, req.Height, -1, 1)
 
@@ -65,6 +72,20 @@ func (h *Service) RenderPixel(ctx context.Context, req *RenderPixelRequest, resp
 	return nil
 }
 
+// SayHello says hello to the name provided
+//
+// Deprecated: Use Hello, which implements the generated SayServer
+func (h *Service) SayHello(ctx context.Context, req *SayHelloRequest, resp *SayHelloResponse) error {
+	return h.Hello(ctx, req, resp)
+}
+
+// RenderPixel returns the color of a Mandelbrot fractal at the given point in the image.
+//
+// Deprecated: Use Pixel, which implements the generated RenderServer
+func (h *Service) RenderPixel(ctx context.Context, req *RenderPixelRequest, resp *RenderPixelResponse) error {
+	return h.Pixel(ctx, req, resp)
+}
+
 func norm(x, total int64, min, max float64) float64 {
 	return (max-min)*float64(x)/float64(total) - max
 }
@@ -0,0 +1,138 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"encoding/json"
+	"errors"
+
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// WithTypedDetails returns a copy of the Error in err with the protobuf messages provided attached as typed
+// details, which are sent to the client via v1.Reply.TypedDetails. This allows services to reply with
+// structured details which can not be expressed as strings in Error.Details(), such as v1.BadRequest.
+//
+//	err := duh.NewBadRequest("invalid user", nil, nil)
+//	return duh.WithTypedDetails(err, &v1.BadRequest{
+//		FieldViolations: []*v1.BadRequest_FieldViolation{
+//			{Field: "user.email", Description: "must be a valid email address"},
+//		},
+//	})
+//
+// If err does not satisfy the Error interface, err is returned unchanged, as errors which are not an Error
+// are replied to the client as CodeInternalError without details. See ReplyError()
+func WithTypedDetails(err error, details ...proto.Message) error {
+	var e Error
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	typed := append([]*anypb.Any(nil), TypedDetails(err)...)
+	for _, d := range details {
+		a, aErr := anypb.New(d)
+		if aErr != nil {
+			return NewServiceError(CodeInternalError, "while marshalling typed detail", aErr, nil)
+		}
+		typed = append(typed, a)
+	}
+
+	if se, ok := err.(*serviceError); ok {
+		c := *se
+		c.typed = typed
+		return &c
+	}
+	return &serviceError{
+		details: e.Details(),
+		typed:   typed,
+		msg:     e.Message(),
+		code:    e.Code(),
+		err:     err,
+	}
+}
+
+// TypedDetails returns the typed details of the first error in the error chain of err which provides
+// them, such as an error returned by WithTypedDetails() or a ClientError. Returns nil if no error
+// in the chain provides typed details.
+func TypedDetails(err error) []*anypb.Any {
+	var t interface{ TypedDetails() []*anypb.Any }
+	if errors.As(err, &t) {
+		return t.TypedDetails()
+	}
+	return nil
+}
+
+// TypedDetail unmarshalls the first typed detail of err which is of the same type as m into m. Returns
+// false if err has no typed detail of the same type as m.
+//
+//	var br v1.BadRequest
+//	if duh.TypedDetail(err, &br) {
+//		for _, v := range br.FieldViolations {
+//			fmt.Printf("%s: %s\n", v.Field, v.Description)
+//		}
+//	}
+func TypedDetail(err error, m proto.Message) bool {
+	for _, a := range TypedDetails(err) {
+		if a.MessageIs(m) {
+			return a.UnmarshalTo(m) == nil
+		}
+	}
+	return false
+}
+
+// unmarshalReply un-marshals the v1.Reply in b. As protojson can not un-marshal a typed detail whose type
+// is not linked into the binary, a JSON reply which fails to un-marshal is un-marshalled again without the
+// typed details, such that the Code, Message and Details of a reply from a newer service are not lost. Only
+// the typed details which can be un-marshalled are then added to the reply.
+func unmarshalReply(c Codec, b []byte, reply *v1.Reply) error {
+	err := unmarshalPooled(c, b, reply)
+	if err == nil || c.ContentType() != ContentTypeJSON {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(b, &fields) != nil {
+		return err
+	}
+	var typed []json.RawMessage
+	for _, key := range []string{"typed_details", "typedDetails"} {
+		if raw, ok := fields[key]; ok {
+			var details []json.RawMessage
+			_ = json.Unmarshal(raw, &details)
+			typed = append(typed, details...)
+			delete(fields, key)
+		}
+	}
+	if typed == nil {
+		return err
+	}
+
+	stripped, mErr := json.Marshal(fields)
+	if mErr != nil {
+		return err
+	}
+	if err := c.Unmarshal(stripped, reply); err != nil {
+		return err
+	}
+	for _, raw := range typed {
+		var a anypb.Any
+		if c.Unmarshal(raw, &a) == nil {
+			reply.TypedDetails = append(reply.TypedDetails, &a)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package duh_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/internal/test"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTypedDetails(t *testing.T) {
+	badRequest := &v1.BadRequest{
+		FieldViolations: []*v1.BadRequest_FieldViolation{
+			{Field: "user.email", Description: "must be a valid email address"},
+		},
+	}
+	info := &v1.ErrorInfo{Reason: "INVALID_EMAIL", Domain: "users.example.com"}
+
+	fail := func(ctx context.Context, req *test.ErrorsRequest, resp *test.ErrorsRequest) error {
+		return duh.WithTypedDetails(duh.NewBadRequest("invalid user", nil, map[string]string{"key": "value"}),
+			badRequest, info)
+	}
+	router := duh.NewRouter()
+	router.Handle("/v1/test.errors", duh.NewHandler(fail, duh.HandlerConfig{}))
+	router.HandleFunc("/v1/test.stream", func(w http.ResponseWriter, r *http.Request) {
+		s, err := duh.NewStreamWriter(w, r)
+		if err != nil {
+			duh.ReplyError(w, r, err)
+			return
+		}
+		_ = s.Send(&test.ErrorsRequest{Case: "item-0"})
+		_ = s.Close(duh.WithTypedDetails(duh.NewTooManyRequests("quota exceeded", nil, nil),
+			&v1.QuotaFailure{Violations: []*v1.QuotaFailure_Violation{{Subject: "account:1234"}}}))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	t.Run("server", func(t *testing.T) {
+		err := fail(ctx, nil, nil)
+		assert.True(t, duh.IsBadRequest(err))
+		require.Len(t, duh.TypedDetails(err), 2)
+
+		var br v1.BadRequest
+		require.True(t, duh.TypedDetail(err, &br))
+		assert.True(t, proto.Equal(badRequest, &br))
+
+		// Details are found when the error is wrapped
+		var ei v1.ErrorInfo
+		require.True(t, duh.TypedDetail(fmt.Errorf("while validating: %w", err), &ei))
+		assert.Equal(t, "INVALID_EMAIL", ei.Reason)
+
+		assert.False(t, duh.TypedDetail(err, &v1.QuotaFailure{}))
+	})
+
+	t.Run("attach to existing details", func(t *testing.T) {
+		err := duh.WithTypedDetails(fail(ctx, nil, nil), &v1.QuotaFailure{})
+		assert.Len(t, duh.TypedDetails(err), 3)
+		assert.Len(t, duh.TypedDetails(fail(ctx, nil, nil)), 2)
+
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "invalid user", e.Message())
+		assert.Equal(t, "value", e.Details()["key"])
+	})
+
+	t.Run("not an Error", func(t *testing.T) {
+		err := duh.WithTypedDetails(io.EOF, badRequest)
+		assert.Equal(t, io.EOF, err)
+		assert.Nil(t, duh.TypedDetails(err))
+		assert.False(t, duh.TypedDetail(err, &v1.BadRequest{}))
+	})
+
+	for _, ct := range []string{duh.ContentTypeJSON, duh.ContentTypeProtoBuf} {
+		t.Run("client "+ct, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.errors",
+				strings.NewReader("{}"))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", duh.ContentTypeJSON)
+			req.Header.Set("Accept", ct)
+
+			err = duh.DefaultClient.Do(req, &test.ErrorsRequest{})
+			assert.True(t, duh.IsBadRequest(err))
+
+			var ce *duh.ClientError
+			require.True(t, errors.As(err, &ce))
+			assert.Equal(t, "invalid user", ce.Message())
+			require.Len(t, ce.TypedDetails(), 2)
+
+			var br v1.BadRequest
+			require.True(t, duh.TypedDetail(err, &br))
+			assert.True(t, proto.Equal(badRequest, &br))
+			var ei v1.ErrorInfo
+			require.True(t, duh.TypedDetail(err, &ei))
+			assert.True(t, proto.Equal(info, &ei))
+		})
+	}
+
+	t.Run("stream trailer", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.stream", nil)
+		require.NoError(t, err)
+		stream, err := duh.DefaultClient.DoStream(req)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		require.NoError(t, stream.Recv(&test.ErrorsRequest{}))
+		err = stream.Recv(&test.ErrorsRequest{})
+		assert.True(t, duh.IsTooManyRequests(err))
+		var qf v1.QuotaFailure
+		require.True(t, duh.TypedDetail(err, &qf))
+		require.Len(t, qf.Violations, 1)
+		assert.Equal(t, "account:1234", qf.Violations[0].Subject)
+	})
+}
+
+func TestUnknownTypedDetails(t *testing.T) {
+	// A newer service may reply with typed details of a type which is not linked into the client
+	const reply = `{"code": 400, "code_text": "Bad Request", "message": "invalid user",
+		"details": {"key": "value"}, "typed_details": [
+			{"@type": "type.googleapis.com/example.v1.Unknown", "field": "value"},
+			{"@type": "type.googleapis.com/duh.v1.ErrorInfo", "reason": "INVALID_EMAIL"}]}`
+
+	router := duh.NewRouter()
+	router.HandleFunc("/v1/test.errors", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", duh.ContentTypeJSON)
+		w.WriteHeader(duh.CodeBadRequest)
+		_, _ = w.Write([]byte(reply))
+	})
+	router.HandleFunc("/v1/test.stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", duh.ContentTypeJSONStream)
+		hdr := []byte{0x01, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(hdr[1:], uint32(len(reply)))
+		_, _ = w.Write(append(hdr, reply...))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	assertReply := func(t *testing.T, err error) {
+		var ce *duh.ClientError
+		require.True(t, errors.As(err, &ce))
+		assert.Equal(t, duh.CodeBadRequest, ce.Code())
+		assert.Equal(t, "invalid user", ce.Message())
+		assert.Equal(t, "value", ce.Details()["key"])
+
+		// Only the typed details of known types are available
+		require.Len(t, ce.TypedDetails(), 1)
+		var ei v1.ErrorInfo
+		require.True(t, duh.TypedDetail(err, &ei))
+		assert.Equal(t, "INVALID_EMAIL", ei.Reason)
+	}
+
+	t.Run("client", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.errors",
+			strings.NewReader("{}"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", duh.ContentTypeJSON)
+
+		assertReply(t, duh.DefaultClient.Do(req, &test.ErrorsRequest{}))
+	})
+
+	t.Run("stream trailer", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.stream", nil)
+		require.NoError(t, err)
+		stream, err := duh.DefaultClient.DoStream(req)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		assertReply(t, stream.Recv(&test.ErrorsRequest{}))
+	})
+}
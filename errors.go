@@ -15,12 +15,14 @@ limitations under the License.
 package duh
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	v1 "github.com/duh-rpc/duh-go/proto/v1"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
 const (
@@ -103,39 +105,47 @@ var _ Error = (*ClientError)(nil)
 
 type serviceError struct {
 	details map[string]string
+	typed   []*anypb.Any
+	msg     string
 	err     error
 	code    int
 }
 
 // NewServiceError returns a new serviceError.
 // Server Implementations should use this to respond to requests with an error.
-// TODO: Ensure you can get the `cause` of the error from serviceError struct
+//
+// The msg is the message sent to the client via v1.Reply.Message, and err is the cause of the error which
+// is never sent to the client. The cause is included in Error() such that it can be logged by the service
+// and is available via errors.Is() and errors.As(). If msg is empty, CodeText(code) is sent to the client.
+//
+//	if err := db.Get(ctx, id, &user); err != nil {
+//		return duh.NewServiceError(duh.CodeInternalError, "while fetching user", err, nil)
+//	}
+//
+// For backward compatibility, a msg which includes the '%w' verb is a format string for the cause as in
+// previous versions. Error() replaces '%w' with the cause, and since the formatted message includes the
+// cause, CodeText(code) is sent to the client instead. Any other '%' in msg is sent to the client as is.
 func NewServiceError(code int, msg string, err error, details map[string]string) error {
-	if msg != "" {
-		if err != nil {
-			err = fmt.Errorf(msg, err)
-		} else {
-			err = errors.New(msg)
-		}
-	}
 	return &serviceError{
 		details: details,
 		code:    code,
+		msg:     msg,
 		err:     err,
 	}
 }
 
+// formatsCause returns true if msg is a format string for the cause, IE: "while fetching user: %w"
+func (e *serviceError) formatsCause() bool {
+	return e.err != nil && strings.Contains(e.msg, "%w")
+}
+
 func (e *serviceError) ProtoMessage() proto.Message {
 	return &v1.Reply{
-		Message: func() string {
-			if e.err != nil {
-				return e.err.Error()
-			}
-			return ""
-		}(),
-		CodeText: CodeText(e.code),
-		Code:     int32(e.code),
-		Details:  e.details,
+		Message:      e.Message(),
+		CodeText:     CodeText(e.code),
+		Code:         int32(e.code),
+		Details:      e.details,
+		TypedDetails: e.typed,
 	}
 }
 
@@ -143,24 +153,55 @@ func (e *serviceError) Code() int {
 	return e.code
 }
 
+// Message returns the message sent to the client, which does not include the cause of the error
 func (e *serviceError) Message() string {
-	return e.err.Error()
+	if e.msg == "" || e.formatsCause() {
+		return CodeText(e.code)
+	}
+	return e.msg
 }
 
+// Error returns the message and the cause of the error, suitable for logging by the service
 func (e *serviceError) Error() string {
-	return CodeText(e.code) + ":" + e.err.Error()
+	switch {
+	case e.formatsCause():
+		return CodeText(e.code) + ":" + strings.Replace(e.msg, "%w", e.err.Error(), 1)
+	case e.err == nil:
+		return CodeText(e.code) + ":" + e.Message()
+	case e.msg == "" || e.msg == e.err.Error():
+		return CodeText(e.code) + ":" + e.err.Error()
+	}
+	return CodeText(e.code) + ":" + e.msg + ": " + e.err.Error()
 }
 
 func (e *serviceError) Details() map[string]string {
 	return e.details
 }
 
+// TypedDetails returns the typed details attached via WithTypedDetails()
+func (e *serviceError) TypedDetails() []*anypb.Any {
+	return e.typed
+}
+
+// Unwrap returns the cause of the error, or nil if no cause was provided
+func (e *serviceError) Unwrap() error {
+	return e.err
+}
+
+// Is returns true if the target is the sentinel error for the code of this error, IE: ErrNotFound
+func (e *serviceError) Is(target error) bool {
+	return isCode(target, e.code)
+}
+
 // TODO: Decide if this should be public or not, I'm leaning toward not being public
 type ClientError struct {
 	details      map[string]string
+	typed        []*anypb.Any
 	msg          string
 	err          error
 	isInfraError bool
+	retryAfter   time.Duration
+	rateLimit    *RateLimit
 	code         int
 }
 
@@ -169,10 +210,11 @@ func (e *ClientError) ProtoMessage() proto.Message {
 		e.msg = e.err.Error()
 	}
 	return &v1.Reply{
-		CodeText: CodeText(e.code),
-		Code:     int32(e.code),
-		Details:  e.details,
-		Message:  e.msg,
+		CodeText:     CodeText(e.code),
+		Code:         int32(e.code),
+		Details:      e.details,
+		Message:      e.msg,
+		TypedDetails: e.typed,
 	}
 }
 
@@ -211,3 +253,41 @@ func (e *ClientError) Error() string {
 func (e *ClientError) Details() map[string]string {
 	return e.details
 }
+
+// TypedDetails returns the typed details the service provided via v1.Reply.TypedDetails
+func (e *ClientError) TypedDetails() []*anypb.Any {
+	return e.typed
+}
+
+// Is returns true if the target is the sentinel error for the code of this error, IE: ErrNotFound
+func (e *ClientError) Is(target error) bool {
+	return isCode(target, e.code)
+}
+
+// Unwrap returns the error which caused the client to fail, such as the error returned by the http.Client or
+// context.DeadlineExceeded. Returns nil if the error was returned by the service or the infrastructure.
+func (e *ClientError) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter returns the duration the client should wait before retrying the request. If the server
+// provided a RateLimit, the duration is calculated from the reset time of the RateLimit, else the
+// duration requested by the `Retry-After` header is returned. Returns zero if the server provided neither.
+func (e *ClientError) RetryAfter() time.Duration {
+	if e.rateLimit != nil && !e.rateLimit.Reset.IsZero() {
+		if d := time.Until(e.rateLimit.Reset); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return e.retryAfter
+}
+
+// RateLimit returns the RateLimit provided by the server via the v1.Reply.Details or
+// the IETF RateLimit headers. Returns false if the server did not provide a RateLimit.
+func (e *ClientError) RateLimit() (RateLimit, bool) {
+	if e.rateLimit == nil {
+		return RateLimit{}, false
+	}
+	return *e.rateLimit, true
+}
@@ -0,0 +1,261 @@
+package duh_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/demo"
+	"github.com/duh-rpc/duh-go/internal/test"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorConstructors(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		code     int
+		sentinel error
+		is       func(error) bool
+	}{
+		{name: "BadRequest", err: duh.NewBadRequest("bad", nil, nil),
+			code: duh.CodeBadRequest, sentinel: duh.ErrBadRequest, is: duh.IsBadRequest},
+		{name: "Unauthorized", err: duh.NewUnauthorized("who", nil, nil),
+			code: duh.CodeUnauthorized, sentinel: duh.ErrUnauthorized, is: duh.IsUnauthorized},
+		{name: "Forbidden", err: duh.NewForbidden("no", nil, nil),
+			code: duh.CodeForbidden, sentinel: duh.ErrForbidden, is: duh.IsForbidden},
+		{name: "NotFound", err: duh.NewNotFound("missing", nil, nil),
+			code: duh.CodeNotFound, sentinel: duh.ErrNotFound, is: duh.IsNotFound},
+		{name: "Conflict", err: duh.NewConflict("conflict", nil, nil),
+			code: duh.CodeConflict, sentinel: duh.ErrConflict, is: duh.IsConflict},
+		{name: "TooManyRequests", err: duh.NewTooManyRequests("slow down", nil, nil),
+			code: duh.CodeTooManyRequests, sentinel: duh.ErrTooManyRequests, is: duh.IsTooManyRequests},
+		{name: "RequestFailed", err: duh.NewRequestFailed("failed", nil, nil),
+			code: duh.CodeRequestFailed, sentinel: duh.ErrRequestFailed, is: duh.IsRequestFailed},
+		{name: "RetryRequest", err: duh.NewRetryRequest("again", nil, nil),
+			code: duh.CodeRetryRequest, sentinel: duh.ErrRetryRequest, is: duh.IsRetryRequest},
+		{name: "ClientContentError", err: duh.NewClientContentError("content", nil, nil),
+			code: duh.CodeClientContentError, sentinel: duh.ErrClientContentError, is: duh.IsClientContentError},
+		{name: "InternalError", err: duh.NewInternalError("internal", nil, nil),
+			code: duh.CodeInternalError, sentinel: duh.ErrInternalError, is: duh.IsInternalError},
+		{name: "NotImplemented", err: duh.NewNotImplemented("not yet", nil, nil),
+			code: duh.CodeNotImplemented, sentinel: duh.ErrNotImplemented, is: duh.IsNotImplemented},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var e duh.Error
+			require.True(t, errors.As(tc.err, &e))
+			assert.Equal(t, tc.code, e.Code())
+			assert.True(t, tc.is(tc.err))
+			assert.True(t, errors.Is(tc.err, tc.sentinel))
+
+			// Must also match when wrapped
+			wrapped := fmt.Errorf("while handling: %w", tc.err)
+			assert.True(t, tc.is(wrapped))
+			assert.True(t, errors.Is(wrapped, tc.sentinel))
+
+			// Must not match other codes
+			other := duh.ErrNotFound
+			if tc.sentinel == duh.ErrNotFound {
+				other = duh.ErrConflict
+			}
+			assert.False(t, errors.Is(tc.err, other))
+		})
+	}
+
+	t.Run("not an Error", func(t *testing.T) {
+		assert.False(t, duh.IsNotFound(io.EOF))
+		assert.False(t, duh.IsInternalError(nil))
+		assert.False(t, errors.Is(io.EOF, duh.ErrInternalError))
+	})
+
+	t.Run("wraps error", func(t *testing.T) {
+		err := duh.NewNotFound("user 'foo' not found", sql.ErrNoRows, map[string]string{"user": "foo"})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "user 'foo' not found", e.Message())
+		assert.Equal(t, map[string]string{"user": "foo"}, e.Details())
+		assert.True(t, duh.IsNotFound(err))
+		assert.True(t, errors.Is(err, sql.ErrNoRows))
+	})
+}
+
+func TestServiceErrorCause(t *testing.T) {
+	cause := fmt.Errorf("while querying: %w", context.DeadlineExceeded)
+
+	t.Run("message", func(t *testing.T) {
+		err := duh.NewServiceError(duh.CodeInternalError, "while fetching user", cause, nil)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, cause, errors.Unwrap(err))
+		assert.Equal(t, "Internal Service Error:while fetching user: while querying: context deadline exceeded",
+			err.Error())
+
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "while fetching user", e.Message())
+		reply := e.ProtoMessage().(*v1.Reply)
+		assert.Equal(t, "while fetching user", reply.Message)
+	})
+
+	t.Run("message with verb", func(t *testing.T) {
+		// The message formats the cause, as such only the code text is sent to the client
+		err := duh.NewServiceError(duh.CodeInternalError, "while fetching user: %w", cause, nil)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, "Internal Service Error:while fetching user: while querying: context deadline exceeded",
+			err.Error())
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "Internal Service Error", e.Message())
+	})
+
+	t.Run("message with percent", func(t *testing.T) {
+		// Only '%w' formats the cause, any other '%' is part of the message sent to the client
+		err := duh.NewServiceError(duh.CodeConflict, "disk is 95% full", cause, nil)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, "Conflict:disk is 95% full: while querying: context deadline exceeded", err.Error())
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "disk is 95% full", e.Message())
+	})
+
+	t.Run("message is the cause", func(t *testing.T) {
+		// IE: a parser error which describes what is wrong with the request
+		err := duh.NewServiceError(duh.CodeClientContentError, demo.ErrNameRequired.Error(), demo.ErrNameRequired, nil)
+		assert.True(t, errors.Is(err, demo.ErrNameRequired))
+		assert.Equal(t, "Client Content Error:name is empty", err.Error())
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "name is empty", e.Message())
+	})
+
+	t.Run("no message", func(t *testing.T) {
+		err := duh.NewServiceError(duh.CodeBadRequest, "", demo.ErrNameRequired, nil)
+		assert.True(t, errors.Is(err, demo.ErrNameRequired))
+		assert.Equal(t, "Bad Request:name is empty", err.Error())
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "Bad Request", e.Message())
+		assert.Equal(t, "Bad Request", e.ProtoMessage().(*v1.Reply).Message)
+	})
+
+	t.Run("no cause", func(t *testing.T) {
+		err := duh.NewServiceError(duh.CodeNotFound, "no such user", nil, nil)
+		assert.Nil(t, errors.Unwrap(err))
+		assert.Equal(t, "Not Found:no such user", err.Error())
+	})
+
+	t.Run("cause is not sent to the client", func(t *testing.T) {
+		fail := func(ctx context.Context, req *test.ErrorsRequest, resp *test.ErrorsRequest) error {
+			return duh.NewServiceError(duh.CodeInternalError, "while reading the database", cause, nil)
+		}
+		server := httptest.NewServer(duh.NewHandler(fail, duh.HandlerConfig{}))
+		defer server.Close()
+
+		c := test.NewClient(test.ClientConfig{Endpoint: server.URL})
+		err := c.TestErrors(context.Background(), &test.ErrorsRequest{})
+		require.Error(t, err)
+		assert.True(t, duh.IsInternalError(err))
+		assert.False(t, errors.Is(err, context.DeadlineExceeded))
+
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "while reading the database", e.Message())
+	})
+
+	t.Run("no message sends the code text to the client", func(t *testing.T) {
+		fail := func(ctx context.Context, req *test.ErrorsRequest, resp *test.ErrorsRequest) error {
+			return duh.NewBadRequest("", cause, nil)
+		}
+		server := httptest.NewServer(duh.NewHandler(fail, duh.HandlerConfig{}))
+		defer server.Close()
+
+		c := test.NewClient(test.ClientConfig{Endpoint: server.URL})
+		err := c.TestErrors(context.Background(), &test.ErrorsRequest{})
+		require.Error(t, err)
+		assert.True(t, duh.IsBadRequest(err))
+		assert.NotContains(t, err.Error(), "while querying")
+
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "Bad Request", e.Message())
+	})
+}
+
+func TestWrappedErrorPredicates(t *testing.T) {
+	notFound := duh.NewNotFound("user not found", nil, nil)
+	err := duh.NewInternalError("while fetching user", notFound, nil)
+
+	// The predicates search the entire chain in the same way as errors.Is()
+	assert.True(t, errors.Is(err, duh.ErrNotFound))
+	assert.True(t, duh.IsNotFound(err))
+	assert.True(t, errors.Is(err, duh.ErrInternalError))
+	assert.True(t, duh.IsInternalError(err))
+	assert.False(t, errors.Is(err, duh.ErrBadRequest))
+	assert.False(t, duh.IsBadRequest(err))
+
+	// The outermost Error is the code replied to the client
+	var e duh.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, duh.CodeInternalError, e.Code())
+
+	joined := errors.Join(errors.New("cache miss"), fmt.Errorf("while fetching user: %w", notFound))
+	assert.True(t, errors.Is(joined, duh.ErrNotFound))
+	assert.True(t, duh.IsNotFound(joined))
+	assert.False(t, duh.IsInternalError(joined))
+}
+
+func TestClientErrorPredicates(t *testing.T) {
+	server := httptest.NewServer(demo.NewHandler(demo.NewService()))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	say := demo.NewSayClient(nil, server.URL)
+	err := say.Hello(ctx, &demo.SayHelloRequest{Name: "lowercase"}, &demo.SayHelloResponse{})
+	require.Error(t, err)
+
+	var ce *duh.ClientError
+	require.True(t, errors.As(err, &ce))
+	assert.True(t, duh.IsBadRequest(err))
+	assert.True(t, errors.Is(err, duh.ErrBadRequest))
+	assert.False(t, errors.Is(err, duh.ErrInternalError))
+	assert.False(t, duh.IsClientError(err))
+
+	// A client which can not connect returns a ClientError with CodeClientError
+	server.Close()
+	err = say.Hello(ctx, &demo.SayHelloRequest{Name: "Admiral Thrawn"}, &demo.SayHelloResponse{})
+	require.Error(t, err)
+	assert.True(t, duh.IsClientError(err))
+	assert.True(t, errors.Is(err, duh.ErrClientError))
+	assert.False(t, duh.IsBadRequest(err))
+	var urlErr *url.Error
+	assert.True(t, errors.As(err, &urlErr))
+}
+
+func TestClientErrorCause(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	say := demo.NewSayClient(nil, server.URL)
+	err := say.Hello(ctx, &demo.SayHelloRequest{Name: "Admiral Thrawn"}, &demo.SayHelloResponse{})
+	require.Error(t, err)
+	assert.True(t, duh.IsClientError(err))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
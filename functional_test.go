@@ -19,8 +19,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/duh-rpc/duh-go"
 	"github.com/duh-rpc/duh-go/demo"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http/httptest"
 )
 
@@ -29,7 +31,7 @@ func TestDemoHappyPath(t *testing.T) {
 	service := demo.NewService()
 
 	// Create a new server which handles the HTTP requests for our service
-	server := httptest.NewServer(&demo.Handler{Service: service})
+	server := httptest.NewServer(demo.NewHandler(service))
 	defer server.Close()
 
 	// Create a new client to make RPC calls to the service via the HTTP Handler
@@ -64,7 +66,43 @@ func TestDemoHappyPath(t *testing.T) {
 	}
 }
 
-// TODO: Client example of passing `application/octet-stream` with `duh.DoBytes()`
+func TestDemoGeneratedClients(t *testing.T) {
+	server := httptest.NewServer(demo.NewHandler(demo.NewService()))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	for _, ct := range []string{duh.ContentTypeProtoBuf, duh.ContentTypeJSON} {
+		t.Run(ct, func(t *testing.T) {
+			// The generated clients use protobuf unless another content type is provided
+			say := demo.NewSayClient(nil, server.URL)
+			render := demo.NewRenderClient(nil, server.URL)
+			if ct != duh.ContentTypeProtoBuf {
+				say = say.WithContentType(ct)
+				render = render.WithContentType(ct)
+			}
+
+			var hello demo.SayHelloResponse
+			require.NoError(t, say.Hello(ctx, &demo.SayHelloRequest{Name: "Admiral Thrawn"}, &hello))
+			assert.Equal(t, "Hello, Admiral Thrawn", hello.Message)
+
+			var pixel demo.RenderPixelResponse
+			require.NoError(t, render.Pixel(ctx, &demo.RenderPixelRequest{
+				Complexity: 1024,
+				Height:     2048,
+				Width:      2048,
+				I:          1,
+				J:          1,
+			}, &pixel))
+			assert.Equal(t, int64(72), pixel.Gray)
+
+			err := say.Hello(ctx, &demo.SayHelloRequest{Name: "lowercase"}, &hello)
+			assert.True(t, duh.IsBadRequest(err))
+		})
+	}
+}
+
 // TODO: Update the benchmark tests
 
 // TODO: DUH-RPC Validation Test for any endpoint
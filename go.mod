@@ -4,7 +4,9 @@ go 1.21.7
 
 require (
 	github.com/kapetan-io/tackle v0.1.0
+	github.com/klauspost/compress v1.17.11
 	github.com/stretchr/testify v1.9.0
+	github.com/vmihailenco/msgpack/v5 v5.4.1
 	golang.org/x/net v0.15.0
 	golang.org/x/text v0.13.0
 	google.golang.org/protobuf v1.31.0
@@ -14,4 +16,5 @@ require (
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 )
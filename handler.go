@@ -0,0 +1,97 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultReadLimit is the maximum size of a request body read by handlers created with NewHandler
+// if HandlerConfig.ReadLimit is not provided.
+const DefaultReadLimit = 5 * MegaByte
+
+type HandlerConfig struct {
+	// ReadLimit is the maximum number of bytes read from the request body before the
+	// handler replies with CodeBadRequest. If zero, DefaultReadLimit is used. If negative,
+	// there is no limit on the size of the request body.
+	ReadLimit int64
+	// Codecs are the codecs supported by the method, which allows the supported content types
+	// to differ per method. If nil, DefaultCodecs is used.
+	Codecs *Codecs
+}
+
+// NewHandler returns an http.Handler which reads the request into a new Req, calls the service method
+// provided and replies with the Resp, or the error returned by the service method. Content negotiation
+// is handled by ReadRequest() and Reply(). The handler returned is intended to be registered with a Router,
+// and implements Describer such that the request and response types are available via Router.MethodInfos().
+//
+//	router := duh.NewRouter()
+//	router.Handle("/v1/say.hello", duh.NewHandler(service.SayHello, duh.HandlerConfig{}))
+func NewHandler[Req, Resp any, PReq interface {
+	*Req
+	proto.Message
+}, PResp interface {
+	*Resp
+	proto.Message
+}](fn func(context.Context, PReq, PResp) error, conf HandlerConfig) http.Handler {
+	if fn == nil {
+		panic("duh: nil service method provided to NewHandler")
+	}
+	if conf.ReadLimit == 0 {
+		conf.ReadLimit = DefaultReadLimit
+	}
+	return &handler[Req, Resp, PReq, PResp]{fn: fn, conf: conf}
+}
+
+type handler[Req, Resp any, PReq interface {
+	*Req
+	proto.Message
+}, PResp interface {
+	*Resp
+	proto.Message
+}] struct {
+	fn   func(context.Context, PReq, PResp) error
+	conf HandlerConfig
+}
+
+func (h *handler[Req, Resp, PReq, PResp]) Describe() MethodInfo {
+	return MethodInfo{
+		Request:  PReq(new(Req)).ProtoReflect().Descriptor(),
+		Response: PResp(new(Resp)).ProtoReflect().Descriptor(),
+		Codecs:   h.conf.Codecs,
+	}
+}
+
+func (h *handler[Req, Resp, PReq, PResp]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.conf.Codecs != nil {
+		r = r.WithContext(ContextWithCodecs(r.Context(), h.conf.Codecs))
+	}
+
+	req := PReq(new(Req))
+	if err := ReadRequest(r, req, h.conf.ReadLimit); err != nil {
+		ReplyError(w, r, err)
+		return
+	}
+
+	resp := PResp(new(Resp))
+	if err := h.fn(r.Context(), req, resp); err != nil {
+		ReplyError(w, r, err)
+		return
+	}
+	Reply(w, r, CodeOK, resp)
+}
@@ -0,0 +1,106 @@
+package duh_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/demo"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	json "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewHandler(t *testing.T) {
+	service := demo.NewService()
+	h := duh.NewHandler(service.Hello, duh.HandlerConfig{ReadLimit: 50 * duh.Bytes})
+
+	t.Run("json", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/v1/say.hello", strings.NewReader(`{"name":"Grand Admiral"}`))
+		r.Header.Set("Content-Type", duh.ContentTypeJSON)
+		h.ServeHTTP(w, r)
+		require.Equal(t, duh.CodeOK, w.Code)
+		assert.Equal(t, duh.ContentTypeJSON, w.Header().Get("Content-Type"))
+
+		var resp demo.SayHelloResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "Hello, Grand Admiral", resp.Message)
+	})
+
+	t.Run("protobuf", func(t *testing.T) {
+		b, err := proto.Marshal(&demo.SayHelloRequest{Name: "Grand Admiral"})
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/v1/say.hello", bytes.NewReader(b))
+		r.Header.Set("Content-Type", duh.ContentTypeProtoBuf)
+		r.Header.Set("Accept", duh.ContentTypeProtoBuf)
+		h.ServeHTTP(w, r)
+		require.Equal(t, duh.CodeOK, w.Code)
+		assert.Equal(t, duh.ContentTypeProtoBuf, w.Header().Get("Content-Type"))
+
+		var resp demo.SayHelloResponse
+		require.NoError(t, proto.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "Hello, Grand Admiral", resp.Message)
+	})
+
+	for _, tt := range []struct {
+		name string
+		body string
+		msg  string
+		code int
+	}{
+		{
+			name: "service error",
+			body: `{"name":"grand admiral"}`,
+			msg:  "'name' must be capitalized",
+			code: duh.CodeBadRequest,
+		},
+		{
+			name: "exceeds read limit",
+			body: `{"name":"` + strings.Repeat("A", 50) + `"}`,
+			msg:  "request body exceeds 50B limit",
+			code: duh.CodeBadRequest,
+		},
+		{
+			name: "malformed request",
+			body: `{"name":`,
+			msg:  "proto:",
+			code: duh.CodeClientContentError,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/say.hello", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", duh.ContentTypeJSON)
+			h.ServeHTTP(w, r)
+			assert.Equal(t, tt.code, w.Code)
+
+			var reply v1.Reply
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reply))
+			assert.Equal(t, int32(tt.code), reply.Code)
+			assert.Contains(t, reply.Message, tt.msg)
+		})
+	}
+
+	t.Run("default read limit", func(t *testing.T) {
+		h := duh.NewHandler(func(ctx context.Context, req *demo.SayHelloRequest, resp *demo.SayHelloResponse) error {
+			resp.Message = req.Name
+			return nil
+		}, duh.HandlerConfig{})
+
+		w := httptest.NewRecorder()
+		body := `{"name":"` + strings.Repeat("A", duh.DefaultReadLimit) + `"}`
+		r := httptest.NewRequest(http.MethodPost, "/v1/say.hello", strings.NewReader(body))
+		h.ServeHTTP(w, r)
+		assert.Equal(t, duh.CodeBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "request body exceeds 5.0MB limit")
+	})
+}
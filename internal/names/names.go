@@ -0,0 +1,70 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package names maps protobuf service and rpc names to DUH-RPC method paths, such that
+// protoc-gen-duh and the openapi package agree on the path of every method.
+package names
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var versionRegex = regexp.MustCompile(`^v[0-9]+`)
+
+// Version returns the version used in the DUH-RPC method path which is derived
+// from the last element of the proto package. IE: `package duh.v1;` results in `v1`
+func Version(pkg protoreflect.FullName) (string, error) {
+	version := string(pkg)
+	if i := strings.LastIndex(version, "."); i >= 0 {
+		version = version[i+1:]
+	}
+	if !versionRegex.MatchString(version) {
+		return "", fmt.Errorf("package '%s' must end with a version, IE: 'package %s.v1;'", pkg, pkg)
+	}
+	return version, nil
+}
+
+// MethodPath returns the DUH-RPC method path in the form `/<version>/<service>.<rpc>`
+func MethodPath(version string, service, rpc protoreflect.Name) string {
+	return fmt.Sprintf("/%s/%s.%s", version, KebabCase(string(service)), KebabCase(string(rpc)))
+}
+
+// KebabCase converts a CamelCase proto name into lower kebab-case. IE: `UserAccounts` becomes `user-accounts`
+func KebabCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if r == '_' {
+			b.WriteRune('-')
+			continue
+		}
+		if unicode.IsUpper(r) {
+			// Insert a separator at the start of each new word, treating runs of
+			// upper case letters (IE: `HTTPServer`) as a single word.
+			if i > 0 && runes[i-1] != '_' && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteRune('-')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package names_test
+
+import (
+	"testing"
+
+	"github.com/duh-rpc/duh-go/internal/names"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKebabCase(t *testing.T) {
+	for _, tt := range []struct {
+		in  string
+		out string
+	}{
+		{in: "Say", out: "say"},
+		{in: "Hello", out: "hello"},
+		{in: "UserAccounts", out: "user-accounts"},
+		{in: "userAccounts", out: "user-accounts"},
+		{in: "HTTPServer", out: "http-server"},
+		{in: "GetV2Status", out: "get-v2-status"},
+		{in: "user_accounts", out: "user-accounts"},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.out, names.KebabCase(tt.in))
+		})
+	}
+}
+
+func TestMethodPath(t *testing.T) {
+	version, err := names.Version("users.v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "/v1/user-accounts.create", names.MethodPath(version, "UserAccounts", "Create"))
+
+	_, err = names.Version("users")
+	assert.EqualError(t, err, "package 'users' must end with a version, IE: 'package users.v1;'")
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MethodsMethod is the path of the introspection method which lists the methods registered with a Router.
+// See NewMethodsHandler()
+const MethodsMethod = "/v1/duh.methods"
+
+// MethodInfo describes a method registered with a Router
+type MethodInfo struct {
+	// Path is the DUH-RPC method path, IE: `/v1/say.hello`
+	Path string
+	// Request is the descriptor of the request message, nil if unknown
+	Request protoreflect.MessageDescriptor
+	// Response is the descriptor of the response message, nil if unknown
+	Response protoreflect.MessageDescriptor
+	// Codecs are the codecs supported by the method, nil if the method uses the codecs of the Router
+	Codecs *Codecs
+}
+
+// Describer is implemented by handlers which can describe the method they handle, such as the
+// handlers returned by NewHandler(). The Router sets the MethodInfo.Path when the handler is registered.
+type Describer interface {
+	Describe() MethodInfo
+}
+
+// NewMethodsHandler returns a handler for the introspection method MethodsMethod, which replies with
+// a v1.MethodsResponse listing the path, request and response types and content types of every method
+// registered with the Router, along with the serialized file descriptors of the request and response
+// types. This allows tools to discover the API of a running service. The introspection method is opt-in,
+// as not every service wishes to expose its API.
+//
+//	router := duh.NewRouter()
+//	demo.RegisterSayServer(router, service, duh.HandlerConfig{})
+//	router.Handle(duh.MethodsMethod, duh.NewMethodsHandler(router))
+func NewMethodsHandler(r *Router) http.Handler {
+	return NewHandler(func(ctx context.Context, _ *v1.MethodsRequest, resp *v1.MethodsResponse) error {
+		files := fileCollector{seen: make(map[string]bool)}
+		for _, info := range r.MethodInfos() {
+			m := &v1.Method{Path: info.Path}
+			if info.Request != nil {
+				m.RequestType = string(info.Request.FullName())
+				files.add(info.Request.ParentFile())
+			}
+			if info.Response != nil {
+				m.ResponseType = string(info.Response.FullName())
+				files.add(info.Response.ParentFile())
+			}
+
+			// Methods without codecs use the codecs of the Router, which are provided
+			// via the context by middleware like WithCodecs()
+			codecs := info.Codecs
+			if codecs == nil {
+				codecs = CodecsFromContext(ctx)
+			}
+			m.ContentTypes = codecs.ContentTypes()
+			resp.Methods = append(resp.Methods, m)
+		}
+
+		for _, fd := range files.files {
+			b, err := proto.Marshal(protodesc.ToFileDescriptorProto(fd))
+			if err != nil {
+				return NewServiceError(CodeInternalError,
+					fmt.Sprintf("while marshalling file descriptor '%s'", fd.Path()), err, nil)
+			}
+			resp.FileDescriptors = append(resp.FileDescriptors, b)
+		}
+		return nil
+	}, HandlerConfig{})
+}
+
+// fileCollector collects file descriptors and their dependencies, such that
+// dependencies are listed before the files which import them.
+type fileCollector struct {
+	seen  map[string]bool
+	files []protoreflect.FileDescriptor
+}
+
+func (c *fileCollector) add(fd protoreflect.FileDescriptor) {
+	if c.seen[fd.Path()] {
+		return
+	}
+	c.seen[fd.Path()] = true
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		c.add(imports.Get(i).FileDescriptor)
+	}
+	c.files = append(c.files, fd)
+}
@@ -0,0 +1,90 @@
+package duh_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/demo"
+	"github.com/duh-rpc/duh-go/internal/test"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestMethods(t *testing.T) {
+	echo := func(ctx context.Context, req *test.ErrorsRequest, resp *test.ErrorsRequest) error {
+		return nil
+	}
+
+	router := duh.NewRouter()
+	router.Handle("/v1/test.echo", duh.NewHandler(echo, duh.HandlerConfig{
+		Codecs: duh.NewCodecs(duh.JSONCodec{}),
+	}))
+	router.HandleFunc("/v1/test.stream", func(w http.ResponseWriter, r *http.Request) {})
+	demo.RegisterSayServer(router, demo.NewService(), duh.HandlerConfig{})
+	router.Handle(duh.MethodsMethod, duh.NewMethodsHandler(router))
+
+	t.Run("registry", func(t *testing.T) {
+		infos := router.MethodInfos()
+		require.Len(t, infos, 4)
+		assert.Equal(t, "/v1/duh.methods", infos[0].Path)
+		assert.Equal(t, "/v1/say.hello", infos[1].Path)
+		assert.Equal(t, "duh.v1.SayHelloRequest", string(infos[1].Request.FullName()))
+		assert.Equal(t, "duh.v1.SayHelloResponse", string(infos[1].Response.FullName()))
+		assert.Nil(t, infos[1].Codecs)
+		assert.Equal(t, "/v1/test.echo", infos[2].Path)
+		assert.NotNil(t, infos[2].Codecs)
+		assert.Equal(t, "/v1/test.stream", infos[3].Path)
+		assert.Nil(t, infos[3].Request)
+	})
+
+	t.Run("introspection", func(t *testing.T) {
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodPost, server.URL+duh.MethodsMethod, strings.NewReader("{}"))
+		require.NoError(t, err)
+		var resp v1.MethodsResponse
+		require.NoError(t, duh.DefaultClient.Do(req, &resp))
+
+		require.Len(t, resp.Methods, 4)
+		assert.True(t, proto.Equal(&v1.Method{
+			Path:         "/v1/say.hello",
+			RequestType:  "duh.v1.SayHelloRequest",
+			ResponseType: "duh.v1.SayHelloResponse",
+			ContentTypes: []string{duh.ContentTypeJSON, duh.ContentTypeProtoBuf},
+		}, resp.Methods[1]))
+		assert.Equal(t, []string{duh.ContentTypeJSON}, resp.Methods[2].ContentTypes)
+		assert.True(t, proto.Equal(&v1.Method{
+			Path:         "/v1/test.stream",
+			ContentTypes: []string{duh.ContentTypeJSON, duh.ContentTypeProtoBuf},
+		}, resp.Methods[3]))
+
+		// The file descriptors can be used to build the message types without the generated code
+		var set descriptorpb.FileDescriptorSet
+		for _, b := range resp.FileDescriptors {
+			var fd descriptorpb.FileDescriptorProto
+			require.NoError(t, proto.Unmarshal(b, &fd))
+			set.File = append(set.File, &fd)
+		}
+		files, err := protodesc.NewFiles(&set)
+		require.NoError(t, err)
+		for _, m := range resp.Methods {
+			if m.RequestType == "" {
+				continue
+			}
+			_, err := files.FindDescriptorByName(protoreflect.FullName(m.RequestType))
+			assert.NoError(t, err, m.RequestType)
+			_, err = files.FindDescriptorByName(protoreflect.FullName(m.ResponseType))
+			assert.NoError(t, err, m.ResponseType)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Middleware wraps the handler of a DUH-RPC method. Since middleware is applied to each method
+// when the method is registered with the Router, the middleware knows the resolved DUH-RPC method
+// name (IE: `/v1/say.hello`) which allows middleware to make per method decisions without having
+// to inspect the request path on every request.
+//
+// Middleware which rejects a request SHOULD reply using ReplyWithCode() such that the client
+// receives a proper v1.Reply and knows the rejection came from the service and not the infrastructure.
+type Middleware func(method string, next http.Handler) http.Handler
+
+// Chain wraps the handler with the middleware provided. Middleware is applied in the order provided,
+// such that the first middleware is the outermost and is the first to see the request.
+func Chain(method string, h http.Handler, mw ...Middleware) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](method, h)
+	}
+	return h
+}
+
+// HTTPMiddleware adapts standard `func(http.Handler) http.Handler` middleware into Middleware
+func HTTPMiddleware(mw func(http.Handler) http.Handler) Middleware {
+	return func(_ string, next http.Handler) http.Handler {
+		return mw(next)
+	}
+}
+
+// Recover returns Middleware which recovers from a panic in the handler, logs the panic and replies
+// with CodeInternalError. A panic of http.ErrAbortHandler is not recovered, as it is used to abort
+// a response. Recover should be the first middleware in the chain.
+func Recover(log StandardLogger) Middleware {
+	if log == nil {
+		log = NoOpLogger{}
+	}
+	return func(method string, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if p := recover(); p != nil {
+					if p == http.ErrAbortHandler {
+						panic(p)
+					}
+					log.Error("recovered from panic in handler", "method", method, "panic", fmt.Sprint(p))
+					ReplyWithCode(w, r, CodeInternalError, nil, "internal service error")
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ReadLimit returns Middleware which limits the number of bytes read from the request body. Handlers
+// which read more than the limit will receive ErrDataLimitExceeded when reading the body.
+func ReadLimit(limit int64) Middleware {
+	return func(_ string, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = NewLimitReader(r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithCodecs returns Middleware which negotiates content types using the codecs provided for every method
+// registered with the Router, which allows the codecs and their options (See JSONCodec) to be configured for the
+// entire service. HandlerConfig.Codecs takes precedence for individual methods. WithCodecs should be placed
+// before any middleware which replies to the client, such that those replies are also negotiated with the codecs.
+func WithCodecs(c *Codecs) Middleware {
+	return func(_ string, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ContextWithCodecs(r.Context(), c)))
+		})
+	}
+}
+
+// WithCompression returns Middleware which uses the Compression provided to decompress requests and compress
+// replies for every method registered with the Router. WithCompression should be placed before any middleware
+// which replies to the client, such that those replies are also compressed.
+func WithCompression(c *Compression) Middleware {
+	return func(_ string, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ContextWithCompression(r.Context(), c)))
+		})
+	}
+}
+
+// AuthenticateFunc authenticates the request, returning a context which is passed to the handler.
+// Implementations typically add the identity of the authenticated caller to the context returned.
+type AuthenticateFunc func(r *http.Request) (context.Context, error)
+
+// Authenticate returns Middleware which calls the AuthenticateFunc provided for every request. If
+// the AuthenticateFunc returns an error, the request is rejected with CodeUnauthorized. The message of
+// the error is only sent to the client if the error is an Error, IE: duh.NewUnauthorized().
+func Authenticate(fn AuthenticateFunc) Middleware {
+	return func(_ string, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, err := fn(r)
+			if err != nil {
+				reject(w, r, CodeUnauthorized, err)
+				return
+			}
+			if ctx != nil && ctx != r.Context() {
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AuthorizeFunc returns an error if the caller is not authorized to call the DUH-RPC method provided.
+type AuthorizeFunc func(r *http.Request, method string) error
+
+// Authorize returns Middleware which calls the AuthorizeFunc provided for every request. If
+// the AuthorizeFunc returns an error, the request is rejected with CodeForbidden. As with Authenticate,
+// the message of the error is only sent to the client if the error is an Error, IE: duh.NewForbidden().
+// Authorize should be placed after Authenticate in the middleware chain.
+func Authorize(fn AuthorizeFunc) Middleware {
+	return func(method string, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := fn(r, method); err != nil {
+				reject(w, r, CodeForbidden, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// reject replies with the code provided. If the error is an Error, the message and details
+// of the error are included in the reply, else the reply only includes CodeText(code), as the
+// error may include details of the backend the client should not see, IE: token store errors.
+func reject(w http.ResponseWriter, r *http.Request, code int, err error) {
+	var e Error
+	if errors.As(err, &e) {
+		ReplyWithCode(w, r, code, e.Details(), e.Message())
+		return
+	}
+	ReplyWithCode(w, r, code, nil, CodeText(code))
+}
@@ -0,0 +1,160 @@
+package duh_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duh-rpc/duh-go"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	json "google.golang.org/protobuf/encoding/protojson"
+)
+
+type userKey struct{}
+
+func TestMiddleware(t *testing.T) {
+	var order []string
+	trace := func(name string) duh.Middleware {
+		return func(method string, next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name+":"+method)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	router := duh.NewRouter()
+	router.Use(
+		duh.Recover(nil),
+		trace("first"),
+		trace("second"),
+		duh.ReadLimit(10),
+		duh.Authenticate(func(r *http.Request) (context.Context, error) {
+			user := r.Header.Get("X-User")
+			switch user {
+			case "":
+				return nil, duh.NewUnauthorized("missing X-User header", nil, nil)
+			case "unavailable":
+				return nil, errors.New("token store: dial tcp 10.0.0.12:6379: connection refused")
+			}
+			return context.WithValue(r.Context(), userKey{}, user), nil
+		}),
+		duh.Authorize(func(r *http.Request, method string) error {
+			if method == "/v1/admin.delete" && r.Context().Value(userKey{}) != "admin" {
+				return duh.NewServiceError(duh.CodeForbidden, "admin only", nil,
+					map[string]string{"role": "admin"})
+			}
+			return nil
+		}),
+	)
+	router.HandleFunc("/v1/say.hello", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			duh.ReplyError(w, r, err)
+			return
+		}
+		duh.ReplyWithCode(w, r, duh.CodeOK, nil, "hello "+r.Context().Value(userKey{}).(string))
+	})
+	router.HandleFunc("/v1/admin.delete", func(w http.ResponseWriter, r *http.Request) {
+		duh.ReplyWithCode(w, r, duh.CodeOK, nil, "deleted")
+	})
+	router.HandleFunc("/v1/say.panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	assert.Panics(t, func() { router.Use(trace("late")) })
+
+	for _, tt := range []struct {
+		details map[string]string
+		name    string
+		path    string
+		user    string
+		body    string
+		msg     string
+		code    int
+	}{
+		{
+			name: "authenticated",
+			path: "/v1/say.hello",
+			user: "thrawn",
+			msg:  "hello thrawn",
+			code: duh.CodeOK,
+		},
+		{
+			name: "not authenticated",
+			path: "/v1/say.hello",
+			msg:  "missing X-User header",
+			code: duh.CodeUnauthorized,
+		},
+		{
+			name: "authenticate error is not sent to the client",
+			path: "/v1/say.hello",
+			user: "unavailable",
+			msg:  "Unauthorized",
+			code: duh.CodeUnauthorized,
+		},
+		{
+			name:    "not authorized",
+			path:    "/v1/admin.delete",
+			user:    "thrawn",
+			msg:     "admin only",
+			details: map[string]string{"role": "admin"},
+			code:    duh.CodeForbidden,
+		},
+		{
+			name: "authorized",
+			path: "/v1/admin.delete",
+			user: "admin",
+			msg:  "deleted",
+			code: duh.CodeOK,
+		},
+		{
+			name: "exceeds read limit",
+			path: "/v1/say.hello",
+			user: "thrawn",
+			body: "more than ten bytes",
+			msg:  "exceeds 10B limit",
+			code: duh.CodeBadRequest,
+		},
+		{
+			name: "recover from panic",
+			path: "/v1/say.panic",
+			user: "thrawn",
+			msg:  "internal service error",
+			code: duh.CodeInternalError,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			order = nil
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.user != "" {
+				r.Header.Set("X-User", tt.user)
+			}
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, []string{"first:" + tt.path, "second:" + tt.path}, order)
+
+			var reply v1.Reply
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reply))
+			assert.Equal(t, int32(tt.code), reply.Code)
+			assert.Equal(t, tt.msg, reply.Message)
+			for k, v := range tt.details {
+				assert.Equal(t, v, reply.Details[k])
+			}
+		})
+	}
+
+	t.Run("not called for unknown methods", func(t *testing.T) {
+		order = nil
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/say.goodbye", nil))
+		assert.Equal(t, duh.CodeNotImplemented, w.Code)
+		assert.Empty(t, order)
+	})
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package msgpack provides a duh.Codec which encodes messages as MessagePack
+package msgpack
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	ContentType       = "application/msgpack"
+	ContentTypeStream = "application/duh-stream+msgpack"
+)
+
+// Codec is a duh.Codec for `application/msgpack`. Each message is encoded as a MessagePack map keyed by the
+// proto field name, where only populated fields are included. Enums are encoded as their numeric value, and
+// repeated and map fields as MessagePack arrays and maps. When decoding, both the proto field name and the
+// JSON field name are accepted and unknown fields are ignored.
+//
+//	codecs := duh.NewCodecs(duh.JSONCodec{}, duh.ProtoBufCodec{}, msgpack.Codec{})
+//	router.Handle(method, duh.NewHandler(service.Method, duh.HandlerConfig{Codecs: codecs}))
+type Codec struct{}
+
+var (
+	_ duh.Codec             = Codec{}
+	_ duh.MarshalAppender   = Codec{}
+	_ duh.PooledUnmarshaler = Codec{}
+)
+
+func (Codec) ContentType() string {
+	return ContentType
+}
+
+func (c Codec) Marshal(m proto.Message) ([]byte, error) {
+	return c.MarshalAppend(nil, m)
+}
+
+func (Codec) MarshalAppend(b []byte, m proto.Message) ([]byte, error) {
+	buf := bytes.NewBuffer(b)
+	if err := encodeMessage(msgpack.NewEncoder(buf), m.ProtoReflect()); err != nil {
+		return b, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Codec) Unmarshal(b []byte, m proto.Message) error {
+	proto.Reset(m)
+	return decodeMessage(msgpack.NewDecoder(bytes.NewReader(b)), m.ProtoReflect())
+}
+
+// UnmarshalPooled implements duh.PooledUnmarshaler, as the decoder copies all values out of the []byte
+func (c Codec) UnmarshalPooled(b []byte, m proto.Message) error {
+	return c.Unmarshal(b, m)
+}
+
+func encodeMessage(enc *msgpack.Encoder, m protoreflect.Message) error {
+	fields := m.Descriptor().Fields()
+
+	var n int
+	for i := 0; i < fields.Len(); i++ {
+		if m.Has(fields.Get(i)) {
+			n++
+		}
+	}
+	if err := enc.EncodeMapLen(n); err != nil {
+		return err
+	}
+
+	// Encode in field order, such that the output is deterministic
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			continue
+		}
+		if err := enc.EncodeString(string(fd.Name())); err != nil {
+			return err
+		}
+		if err := encodeField(enc, fd, m.Get(fd)); err != nil {
+			return fmt.Errorf("field '%s': %w", fd.FullName(), err)
+		}
+	}
+	return nil
+}
+
+func encodeField(enc *msgpack.Encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	switch {
+	case fd.IsList():
+		l := v.List()
+		if err := enc.EncodeArrayLen(l.Len()); err != nil {
+			return err
+		}
+		for i := 0; i < l.Len(); i++ {
+			if err := encodeValue(enc, fd, l.Get(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case fd.IsMap():
+		mp := v.Map()
+		if err := enc.EncodeMapLen(mp.Len()); err != nil {
+			return err
+		}
+		var err error
+		mp.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			if err = encodeValue(enc, fd.MapKey(), k.Value()); err != nil {
+				return false
+			}
+			err = encodeValue(enc, fd.MapValue(), v)
+			return err == nil
+		})
+		return err
+	}
+	return encodeValue(enc, fd, v)
+}
+
+func encodeValue(enc *msgpack.Encoder, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return enc.EncodeBool(v.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return enc.EncodeInt(v.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return enc.EncodeUint(v.Uint())
+	case protoreflect.FloatKind:
+		return enc.EncodeFloat32(float32(v.Float()))
+	case protoreflect.DoubleKind:
+		return enc.EncodeFloat64(v.Float())
+	case protoreflect.StringKind:
+		return enc.EncodeString(v.String())
+	case protoreflect.BytesKind:
+		return enc.EncodeBytes(v.Bytes())
+	case protoreflect.EnumKind:
+		return enc.EncodeInt(int64(v.Enum()))
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return encodeMessage(enc, v.Message())
+	}
+	return fmt.Errorf("unsupported field kind '%s'", fd.Kind())
+}
+
+func decodeMessage(dec *msgpack.Decoder, m protoreflect.Message) error {
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+
+	fields := m.Descriptor().Fields()
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			// Ignore unknown fields
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if isNil, err := decodeNil(dec); err != nil || isNil {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := decodeField(dec, m, fd); err != nil {
+			return fmt.Errorf("field '%s': %w", fd.FullName(), err)
+		}
+	}
+	return nil
+}
+
+// decodeNil returns true if the next value is nil
+func decodeNil(dec *msgpack.Decoder) (bool, error) {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return false, err
+	}
+	if c != msgpcode.Nil {
+		return false, nil
+	}
+	return true, dec.DecodeNil()
+}
+
+func decodeField(dec *msgpack.Decoder, m protoreflect.Message, fd protoreflect.FieldDescriptor) error {
+	switch {
+	case fd.IsList():
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		l := m.Mutable(fd).List()
+		for i := 0; i < n; i++ {
+			v, err := decodeValue(dec, fd, l.NewElement)
+			if err != nil {
+				return err
+			}
+			l.Append(v)
+		}
+		return nil
+	case fd.IsMap():
+		n, err := dec.DecodeMapLen()
+		if err != nil {
+			return err
+		}
+		mp := m.Mutable(fd).Map()
+		for i := 0; i < n; i++ {
+			k, err := decodeValue(dec, fd.MapKey(), nil)
+			if err != nil {
+				return err
+			}
+			v, err := decodeValue(dec, fd.MapValue(), mp.NewValue)
+			if err != nil {
+				return err
+			}
+			mp.Set(k.MapKey(), v)
+		}
+		return nil
+	case fd.Message() != nil:
+		return decodeMessage(dec, m.Mutable(fd).Message())
+	}
+
+	v, err := decodeValue(dec, fd, nil)
+	if err != nil {
+		return err
+	}
+	m.Set(fd, v)
+	return nil
+}
+
+// decodeValue decodes a single value of the field kind provided. newMessage is called to
+// create a new message value when decoding message fields.
+func decodeValue(dec *msgpack.Decoder, fd protoreflect.FieldDescriptor,
+	newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		b, err := dec.DecodeBool()
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := dec.DecodeInt64()
+		if err == nil && (i > math.MaxInt32 || i < math.MinInt32) {
+			err = fmt.Errorf("value '%d' overflows int32", i)
+		}
+		return protoreflect.ValueOfInt32(int32(i)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := dec.DecodeInt64()
+		return protoreflect.ValueOfInt64(i), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		u, err := dec.DecodeUint64()
+		if err == nil && u > math.MaxUint32 {
+			err = fmt.Errorf("value '%d' overflows uint32", u)
+		}
+		return protoreflect.ValueOfUint32(uint32(u)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		u, err := dec.DecodeUint64()
+		return protoreflect.ValueOfUint64(u), err
+	case protoreflect.FloatKind:
+		f, err := dec.DecodeFloat64()
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := dec.DecodeFloat64()
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.StringKind:
+		s, err := dec.DecodeString()
+		return protoreflect.ValueOfString(s), err
+	case protoreflect.BytesKind:
+		b, err := dec.DecodeBytes()
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.EnumKind:
+		i, err := dec.DecodeInt64()
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), err
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		v := newMessage()
+		return v, decodeMessage(dec, v.Message())
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field kind '%s'", fd.Kind())
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgpack_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/demo"
+	"github.com/duh-rpc/duh-go/msgpack"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	vmsgpack "github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	s, err := structpb.NewStruct(map[string]any{
+		"name":   "Derrick",
+		"count":  float64(10),
+		"nested": map[string]any{"list": []any{true, "two", nil}},
+	})
+	require.NoError(t, err)
+
+	for _, m := range []proto.Message{
+		&v1.Reply{Code: 429, CodeText: "Too Many Requests", Message: "slow down",
+			Details: map[string]string{"ratelimit.limit": "10"}},
+		&demo.RenderPixelRequest{Complexity: 1.5, Height: -10, Width: 1 << 40, I: 3, J: 4},
+		// Includes repeated fields, nested messages and enums
+		protodesc.ToFileDescriptorProto(demo.File_demo_demo_proto),
+		// Includes maps of messages and oneofs
+		s,
+		&v1.Reply{},
+	} {
+		b, err := msgpack.Codec{}.Marshal(m)
+		require.NoError(t, err)
+
+		out := m.ProtoReflect().New().Interface()
+		require.NoError(t, msgpack.Codec{}.Unmarshal(b, out))
+		assert.True(t, proto.Equal(m, out), "expected %v got %v", m, out)
+	}
+}
+
+func TestCodecUnmarshal(t *testing.T) {
+	b, err := vmsgpack.Marshal(map[string]any{
+		"code":      int8(3),
+		"code_text": "JSON name",
+		"unknown":   []any{"ignored", map[string]any{"a": 1}},
+		"message":   nil,
+	})
+	require.NoError(t, err)
+
+	var reply v1.Reply
+	require.NoError(t, msgpack.Codec{}.Unmarshal(b, &reply))
+	assert.Equal(t, int32(3), reply.Code)
+	assert.Equal(t, "JSON name", reply.CodeText)
+	assert.Equal(t, "", reply.Message)
+
+	b, err = vmsgpack.Marshal(map[string]any{"code": "not a number"})
+	require.NoError(t, err)
+	assert.Error(t, msgpack.Codec{}.Unmarshal(b, &reply))
+}
+
+type service struct{}
+
+func (service) Hello(_ context.Context, req *demo.SayHelloRequest, resp *demo.SayHelloResponse) error {
+	resp.Message = "Hello, " + req.Name
+	return nil
+}
+
+func TestCodecService(t *testing.T) {
+	codecs := duh.NewCodecs(duh.JSONCodec{}, duh.ProtoBufCodec{}, msgpack.Codec{})
+	router := duh.NewRouter()
+	router.Handle("/v1/say.hello", duh.NewHandler(service{}.Hello, duh.HandlerConfig{Codecs: codecs}))
+	// A method which does not support msgpack
+	router.Handle("/v1/say.json", duh.NewHandler(service{}.Hello, duh.HandlerConfig{}))
+	router.HandleFunc("/v1/say.stream", func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(duh.ContextWithCodecs(r.Context(), codecs))
+		s, err := duh.NewStreamWriter(w, r)
+		if err != nil {
+			duh.ReplyError(w, r, err)
+			return
+		}
+		_ = s.Send(&demo.SayHelloResponse{Message: "Hello, stream"})
+		_ = s.Close(nil)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := &duh.Client{Client: http.DefaultClient, Codecs: codecs}
+	call := func(method string) (*demo.SayHelloResponse, error) {
+		b, err := msgpack.Codec{}.Marshal(&demo.SayHelloRequest{Name: "Admiral Thrawn"})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, server.URL+method, bytes.NewReader(b))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", msgpack.ContentType)
+		req.Header.Set("Accept", msgpack.ContentType)
+
+		var resp demo.SayHelloResponse
+		return &resp, client.Do(req, &resp)
+	}
+
+	resp, err := call("/v1/say.hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Admiral Thrawn", resp.Message)
+
+	_, err = call("/v1/say.json")
+	var e duh.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, duh.CodeClientContentError, e.Code())
+
+	t.Run("stream", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/say.stream", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", msgpack.ContentTypeStream)
+
+		stream, err := client.DoStream(req)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		var m demo.SayHelloResponse
+		require.NoError(t, stream.Recv(&m))
+		assert.Equal(t, "Hello, stream", m.Message)
+		assert.ErrorIs(t, stream.Recv(&m), io.EOF)
+	})
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package openapi generates OpenAPI 3.1 documents from DUH-RPC methods defined by proto service descriptors
+// or registered with a duh.Router, such that DUH-RPC services can be consumed by OpenAPI tooling like API
+// linters, Postman and client generators.
+//
+// Each method is described as a POST operation on the method path which accepts and returns the
+// request and response messages in JSON and protobuf. Every error code a DUH-RPC service may reply
+// with references the shared `duh.v1.Reply` schema.
+//
+//	methods, err := openapi.MethodsFromFile(pb.File_users_v1_users_proto)
+//	if err != nil {
+//		return err
+//	}
+//	doc, err := openapi.Generate(openapi.Config{Title: "Users", Version: "1.0.0"}, methods...)
+//	if err != nil {
+//		return err
+//	}
+//	b, err := doc.YAML()
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/internal/names"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the version of the OpenAPI specification generated
+const Version = "3.1.0"
+
+// ErrorCodes are the codes a DUH-RPC service may reply with in addition to duh.CodeOK, each of
+// which is documented as a response with the `duh.v1.Reply` schema.
+var ErrorCodes = []int{
+	duh.CodeBadRequest,
+	duh.CodeUnauthorized,
+	duh.CodeForbidden,
+	duh.CodeNotFound,
+	duh.CodeConflict,
+	duh.CodeTooManyRequests,
+	duh.CodeRequestFailed,
+	duh.CodeRetryRequest,
+	duh.CodeClientContentError,
+	duh.CodeInternalError,
+	duh.CodeNotImplemented,
+}
+
+// Config configures the document generated by Generate()
+type Config struct {
+	// Title is the title of the API, if empty `DUH-RPC API` is used
+	Title string
+	// Version is the version of the API document, if empty `0.0.0` is used
+	Version string
+	// Description is an optional description of the API
+	Description string
+	// Servers are the optional URLs of the servers which provide the API, IE: `https://api.example.com`
+	Servers []string
+	// ContentTypes are the content types each method accepts and replies with. If empty
+	// duh.ContentTypeJSON and duh.ContentTypeProtoBuf are used.
+	ContentTypes []string
+	// UseProtoNames uses the proto field names instead of the lowerCamelCase JSON names for the properties
+	// of each schema. This should match the JSONCodec.MarshalOptions of the service.
+	UseProtoNames bool
+}
+
+// Method describes a single DUH-RPC method
+type Method struct {
+	// Path is the DUH-RPC method path, IE: `/v1/say.hello`
+	Path string
+	// Request is the descriptor of the request message
+	Request protoreflect.MessageDescriptor
+	// Response is the descriptor of the response message
+	Response protoreflect.MessageDescriptor
+	// Summary is a short summary of the method. MethodsFromService() uses the first line of the rpc comments.
+	Summary string
+	// Description is a long description of the method. MethodsFromService() uses the rpc comments.
+	Description string
+	// Tags group methods in tools like Postman. MethodsFromService() uses the name of the service.
+	Tags []string
+	// Deprecated marks the method as deprecated
+	Deprecated bool
+}
+
+// MethodsFromFile returns the methods of every service defined in the proto file. Method paths are
+// derived in the same way as protoc-gen-duh. See MethodsFromService()
+func MethodsFromFile(fd protoreflect.FileDescriptor) ([]Method, error) {
+	var methods []Method
+	services := fd.Services()
+	for i := 0; i < services.Len(); i++ {
+		m, err := MethodsFromService(services.Get(i))
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, m...)
+	}
+	return methods, nil
+}
+
+// MethodsFromService returns the methods of the service. The method path is derived from the last
+// element of the proto package, and the service and rpc names converted to lower kebab-case in the same
+// way as protoc-gen-duh, IE: `rpc Hello` of `service Say` in `package duh.v1;` results in `/v1/say.hello`
+func MethodsFromService(sd protoreflect.ServiceDescriptor) ([]Method, error) {
+	version, err := names.Version(sd.ParentFile().Package())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", sd.ParentFile().Path(), err)
+	}
+
+	var methods []Method
+	rpcs := sd.Methods()
+	for i := 0; i < rpcs.Len(); i++ {
+		rpc := rpcs.Get(i)
+		if rpc.IsStreamingClient() || rpc.IsStreamingServer() {
+			return nil, fmt.Errorf("%s: streaming rpc '%s' is not supported", sd.ParentFile().Path(),
+				rpc.FullName())
+		}
+
+		description := comments(rpc)
+		summary, _, _ := strings.Cut(description, "\n")
+		methods = append(methods, Method{
+			Path:        names.MethodPath(version, sd.Name(), rpc.Name()),
+			Request:     rpc.Input(),
+			Response:    rpc.Output(),
+			Summary:     summary,
+			Description: description,
+			Tags:        []string{string(sd.Name())},
+			Deprecated:  isDeprecated(rpc),
+		})
+	}
+	return methods, nil
+}
+
+// MethodsFromRouter returns the methods registered with the Router. Methods registered with a handler which
+// does not describe the request and response types (See duh.Describer) are skipped, as their schema is unknown.
+func MethodsFromRouter(r *duh.Router) []Method {
+	var methods []Method
+	for _, info := range r.MethodInfos() {
+		if info.Request == nil || info.Response == nil {
+			continue
+		}
+		methods = append(methods, Method{
+			Path:     info.Path,
+			Request:  info.Request,
+			Response: info.Response,
+		})
+	}
+	return methods
+}
+
+// Generate returns an OpenAPI 3.1 document which describes the methods provided
+func Generate(conf Config, methods ...Method) (*Document, error) {
+	if conf.Title == "" {
+		conf.Title = "DUH-RPC API"
+	}
+	if conf.Version == "" {
+		conf.Version = "0.0.0"
+	}
+	if len(conf.ContentTypes) == 0 {
+		conf.ContentTypes = []string{duh.ContentTypeJSON, duh.ContentTypeProtoBuf}
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       conf.Title,
+			Version:     conf.Version,
+			Description: conf.Description,
+		},
+		Paths: make(map[string]*PathItem, len(methods)),
+		Components: Components{
+			Schemas:   make(map[string]*Schema),
+			Responses: make(map[string]*Response),
+		},
+	}
+	for _, s := range conf.Servers {
+		doc.Servers = append(doc.Servers, Server{URL: s})
+	}
+
+	schemas := &schemaBuilder{schemas: doc.Components.Schemas, useProtoNames: conf.UseProtoNames}
+	reply := schemas.ref((&v1.Reply{}).ProtoReflect().Descriptor())
+	for _, code := range ErrorCodes {
+		doc.Components.Responses[responseName(code)] = &Response{
+			Description: duh.CodeText(code),
+			Content:     content(conf.ContentTypes, reply),
+		}
+	}
+
+	for _, m := range methods {
+		if err := validate(m); err != nil {
+			return nil, err
+		}
+		if _, ok := doc.Paths[m.Path]; ok {
+			return nil, fmt.Errorf("multiple methods with the path '%s'", m.Path)
+		}
+
+		op := &Operation{
+			OperationID: strings.TrimPrefix(m.Path, "/"),
+			Summary:     m.Summary,
+			Description: m.Description,
+			Tags:        m.Tags,
+			Deprecated:  m.Deprecated,
+			RequestBody: &RequestBody{
+				Required: true,
+				Content:  content(conf.ContentTypes, schemas.ref(m.Request)),
+			},
+			Responses: map[string]*Response{
+				fmt.Sprint(duh.CodeOK): {
+					Description: duh.CodeText(duh.CodeOK),
+					Content:     content(conf.ContentTypes, schemas.ref(m.Response)),
+				},
+			},
+		}
+		for _, code := range ErrorCodes {
+			op.Responses[fmt.Sprint(code)] = &Response{Ref: "#/components/responses/" + responseName(code)}
+		}
+		doc.Paths[m.Path] = &PathItem{Post: op}
+	}
+	return doc, nil
+}
+
+// validate returns an error if the method is missing required fields
+func validate(m Method) error {
+	if m.Path == "" || !strings.HasPrefix(m.Path, "/") {
+		return fmt.Errorf("invalid method path '%s'; expected the form '/<version>/<subject>.<method>'", m.Path)
+	}
+	if m.Request == nil || m.Response == nil {
+		return errors.New("method '" + m.Path + "' must have a Request and Response descriptor")
+	}
+	return nil
+}
+
+// content returns the media types for each of the content types provided with the same schema
+func content(contentTypes []string, s *Schema) map[string]*MediaType {
+	c := make(map[string]*MediaType, len(contentTypes))
+	for _, ct := range contentTypes {
+		c[ct] = &MediaType{Schema: s}
+	}
+	return c
+}
+
+// responseName returns the name of the shared response for the code, IE: `TooManyRequests` for 429
+func responseName(code int) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(duh.CodeText(code))
+}
+
+// JSON returns the document encoded as indented JSON
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML returns the document encoded as YAML
+func (d *Document) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(d); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,178 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/demo"
+	"github.com/duh-rpc/duh-go/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerate(t *testing.T) {
+	methods, err := openapi.MethodsFromFile(demo.File_demo_demo_proto)
+	require.NoError(t, err)
+	require.Len(t, methods, 2)
+	assert.Equal(t, "/v1/say.hello", methods[0].Path)
+	assert.Equal(t, []string{"Say"}, methods[0].Tags)
+	assert.Equal(t, "/v1/render.pixel", methods[1].Path)
+
+	doc, err := openapi.Generate(openapi.Config{
+		Title:   "Demo",
+		Version: "1.0.0",
+		Servers: []string{"http://localhost:8080"},
+	}, methods...)
+	require.NoError(t, err)
+
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Equal(t, "Demo", doc.Info.Title)
+	assert.Equal(t, "http://localhost:8080", doc.Servers[0].URL)
+	require.Len(t, doc.Paths, 2)
+
+	op := doc.Paths["/v1/say.hello"].Post
+	require.NotNil(t, op)
+	assert.Equal(t, "v1/say.hello", op.OperationID)
+	assert.True(t, op.RequestBody.Required)
+	for _, ct := range []string{duh.ContentTypeJSON, duh.ContentTypeProtoBuf} {
+		require.Contains(t, op.RequestBody.Content, ct)
+		assert.Equal(t, "#/components/schemas/duh.v1.SayHelloRequest", op.RequestBody.Content[ct].Schema.Ref)
+		assert.Equal(t, "#/components/schemas/duh.v1.SayHelloResponse", op.Responses["200"].Content[ct].Schema.Ref)
+	}
+
+	// Every error code references the shared reply
+	assert.Len(t, op.Responses, len(openapi.ErrorCodes)+1)
+	assert.Equal(t, "#/components/responses/TooManyRequests", op.Responses["429"].Ref)
+	assert.Equal(t, "#/components/responses/ClientContentError", op.Responses["455"].Ref)
+	resp := doc.Components.Responses["TooManyRequests"]
+	require.NotNil(t, resp)
+	assert.Equal(t, "#/components/schemas/duh.v1.Reply", resp.Content[duh.ContentTypeJSON].Schema.Ref)
+
+	reply := doc.Components.Schemas["duh.v1.Reply"]
+	require.NotNil(t, reply)
+	assert.Equal(t, &openapi.Schema{Type: "integer", Format: "int32"}, reply.Properties["code"])
+	assert.Equal(t, &openapi.Schema{Type: "string"}, reply.Properties["code_text"])
+	assert.Equal(t, &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{Type: "string"}},
+		reply.Properties["details"])
+
+	pixel := doc.Components.Schemas["duh.v1.RenderPixelRequest"]
+	require.NotNil(t, pixel)
+	assert.Equal(t, &openapi.Schema{Type: "number", Format: "double"}, pixel.Properties["complexity"])
+	assert.Equal(t, &openapi.Schema{Type: "string", Format: "int64"}, pixel.Properties["height"])
+
+	t.Run("encodes as json and yaml", func(t *testing.T) {
+		b, err := doc.JSON()
+		require.NoError(t, err)
+		var j map[string]any
+		require.NoError(t, json.Unmarshal(b, &j))
+		assert.Equal(t, "3.1.0", j["openapi"])
+		assert.Contains(t, string(b), `"$ref": "#/components/schemas/duh.v1.SayHelloRequest"`)
+
+		b, err = doc.YAML()
+		require.NoError(t, err)
+		var y map[string]any
+		require.NoError(t, yaml.Unmarshal(b, &y))
+		assert.Equal(t, j["paths"], y["paths"])
+	})
+
+	t.Run("from router", func(t *testing.T) {
+		router := duh.NewRouter()
+		demo.RegisterRenderServer(router, demo.NewService(), duh.HandlerConfig{})
+		router.HandleFunc("/v1/test.unknown", func(http.ResponseWriter, *http.Request) {})
+
+		methods := openapi.MethodsFromRouter(router)
+		require.Len(t, methods, 1)
+		assert.Equal(t, "/v1/render.pixel", methods[0].Path)
+		assert.Equal(t, "duh.v1.RenderPixelRequest", string(methods[0].Request.FullName()))
+	})
+
+	t.Run("use proto names", func(t *testing.T) {
+		doc, err := openapi.Generate(openapi.Config{UseProtoNames: true}, methods...)
+		require.NoError(t, err)
+		assert.Equal(t, "DUH-RPC API", doc.Info.Title)
+		assert.Contains(t, doc.Components.Schemas["duh.v1.Reply"].Properties, "codeText")
+	})
+}
+
+func TestGenerateSchemas(t *testing.T) {
+	// Nested, repeated, enum and well known types
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       str("test/v1/test.proto"),
+		Package:    str("test.v1"),
+		Syntax:     str("proto3"),
+		Dependency: []string{"google/protobuf/struct.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: str("Node"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("children", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.v1.Node", true),
+					field("state", 2, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".test.v1.State", false),
+					field("attrs", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Struct", false),
+					field("data", 4, descriptorpb.FieldDescriptorProto_TYPE_BYTES, "", false),
+				},
+			},
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{
+			{
+				Name: str("State"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{
+					{Name: str("STATE_UNKNOWN"), Number: new(int32)},
+				},
+			},
+		},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+
+	node := fd.Messages().ByName("Node")
+	doc, err := openapi.Generate(openapi.Config{}, openapi.Method{Path: "/v1/nodes.get", Request: node,
+		Response: node})
+	require.NoError(t, err)
+
+	s := doc.Components.Schemas["test.v1.Node"]
+	require.NotNil(t, s)
+	assert.Equal(t, &openapi.Schema{Type: "array", Items: &openapi.Schema{Ref: "#/components/schemas/test.v1.Node"}},
+		s.Properties["children"])
+	assert.Equal(t, &openapi.Schema{Ref: "#/components/schemas/test.v1.State"}, s.Properties["state"])
+	assert.Equal(t, &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{}}, s.Properties["attrs"])
+	assert.Equal(t, &openapi.Schema{Type: "string", ContentEncoding: "base64"}, s.Properties["data"])
+	assert.Equal(t, []string{"STATE_UNKNOWN"}, doc.Components.Schemas["test.v1.State"].Enum)
+
+	t.Run("invalid methods", func(t *testing.T) {
+		_, err := openapi.Generate(openapi.Config{}, openapi.Method{Path: "v1/nodes.get"})
+		assert.ErrorContains(t, err, "invalid method path 'v1/nodes.get'")
+
+		_, err = openapi.Generate(openapi.Config{}, openapi.Method{Path: "/v1/nodes.get"})
+		assert.ErrorContains(t, err, "must have a Request and Response descriptor")
+
+		m := openapi.Method{Path: "/v1/nodes.get", Request: node, Response: node}
+		_, err = openapi.Generate(openapi.Config{}, m, m)
+		assert.ErrorContains(t, err, "multiple methods with the path '/v1/nodes.get'")
+	})
+}
+
+func str(s string) *string { return &s }
+
+func field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string,
+	repeated bool) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     str(name),
+		JsonName: str(name),
+		Number:   &number,
+		Type:     typ.Enum(),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = str(typeName)
+	}
+	if repeated {
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	}
+	return f
+}
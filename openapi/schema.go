@@ -0,0 +1,222 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// schemaBuilder converts message and enum descriptors into schemas which describe the JSON encoding of the
+// message as produced by protojson. Messages and enums are added to the component schemas using the
+// full proto name, IE: `duh.v1.Reply`, and referenced where used.
+type schemaBuilder struct {
+	schemas       map[string]*Schema
+	useProtoNames bool
+}
+
+// ref returns a reference to the schema for the message, adding the schema to the components if needed.
+// Well known types are not added to the components, as they are encoded as JSON scalars or objects.
+func (b *schemaBuilder) ref(md protoreflect.MessageDescriptor) *Schema {
+	if s, ok := wellKnownSchema(md.FullName()); ok {
+		return s
+	}
+
+	name := string(md.FullName())
+	if _, ok := b.schemas[name]; !ok {
+		s := &Schema{
+			Type:        "object",
+			Description: comments(md),
+			Deprecated:  isDeprecated(md),
+		}
+		// Add the schema before the fields are resolved, such that a message which refers
+		// to itself is only resolved once.
+		b.schemas[name] = s
+
+		fields := md.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if s.Properties == nil {
+				s.Properties = make(map[string]*Schema, fields.Len())
+			}
+			s.Properties[b.fieldName(fd)] = b.field(fd)
+		}
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// fieldName returns the name of the field in JSON
+func (b *schemaBuilder) fieldName(fd protoreflect.FieldDescriptor) string {
+	if b.useProtoNames {
+		return string(fd.Name())
+	}
+	return fd.JSONName()
+}
+
+// field returns the schema for the field including any comments on the field
+func (b *schemaBuilder) field(fd protoreflect.FieldDescriptor) *Schema {
+	var s *Schema
+	switch {
+	case fd.IsMap():
+		s = &Schema{Type: "object", AdditionalProperties: b.value(fd.MapValue())}
+	case fd.IsList():
+		s = &Schema{Type: "array", Items: b.value(fd)}
+	default:
+		s = b.value(fd)
+	}
+
+	description, deprecated := comments(fd), isDeprecated(fd)
+	if description == "" && !deprecated {
+		return s
+	}
+	// Siblings of `$ref` are allowed by OpenAPI 3.1, but to avoid modifying the shared
+	// schemas of well known types we copy the schema before adding the description.
+	c := *s
+	c.Description, c.Deprecated = description, deprecated
+	return &c
+}
+
+// value returns the schema for a single value of the field
+func (b *schemaBuilder) value(fd protoreflect.FieldDescriptor) *Schema {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return b.ref(fd.Message())
+	case protoreflect.EnumKind:
+		return b.enum(fd.Enum())
+	}
+	return scalarSchema(fd.Kind())
+}
+
+// enum returns a reference to the schema for the enum, adding the schema to the components if needed
+func (b *schemaBuilder) enum(ed protoreflect.EnumDescriptor) *Schema {
+	if ed.FullName() == "google.protobuf.NullValue" {
+		return &Schema{Type: "null"}
+	}
+
+	name := string(ed.FullName())
+	if _, ok := b.schemas[name]; !ok {
+		s := &Schema{
+			Type:        "string",
+			Description: comments(ed),
+			Deprecated:  isDeprecated(ed),
+		}
+		values := ed.Values()
+		for i := 0; i < values.Len(); i++ {
+			s.Enum = append(s.Enum, string(values.Get(i).Name()))
+		}
+		b.schemas[name] = s
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// scalarSchema returns the schema of the scalar kind as encoded by protojson. 64-bit integers are
+// encoded as strings, as JSON numbers cannot represent all 64-bit integers.
+func scalarSchema(kind protoreflect.Kind) *Schema {
+	switch kind {
+	case protoreflect.BoolKind:
+		return &Schema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &Schema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &Schema{Type: "integer", Format: "int64", Minimum: new(int64)}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return &Schema{Type: "string", Format: "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &Schema{Type: "string", Format: "uint64"}
+	case protoreflect.FloatKind:
+		return &Schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &Schema{Type: "number", Format: "double"}
+	case protoreflect.BytesKind:
+		return &Schema{Type: "string", ContentEncoding: "base64"}
+	}
+	return &Schema{Type: "string"}
+}
+
+// wellKnownSchema returns the schema for the well known types which protojson encodes as something
+// other than a JSON object with a property for each field.
+func wellKnownSchema(name protoreflect.FullName) (*Schema, bool) {
+	switch name {
+	case "google.protobuf.Timestamp":
+		return &Schema{Type: "string", Format: "date-time"}, true
+	case "google.protobuf.Duration":
+		return &Schema{Type: "string", Description: "A duration in seconds with up to nine fractional " +
+			"digits, ending with 's'. IE: '1.5s'"}, true
+	case "google.protobuf.FieldMask":
+		return &Schema{Type: "string", Description: "A comma separated list of field paths"}, true
+	case "google.protobuf.Struct":
+		return &Schema{Type: "object", AdditionalProperties: &Schema{}}, true
+	case "google.protobuf.Value":
+		return &Schema{}, true
+	case "google.protobuf.ListValue":
+		return &Schema{Type: "array", Items: &Schema{}}, true
+	case "google.protobuf.Empty":
+		return &Schema{Type: "object"}, true
+	case "google.protobuf.Any":
+		return &Schema{
+			Type:                 "object",
+			Properties:           map[string]*Schema{"@type": {Type: "string"}},
+			AdditionalProperties: &Schema{},
+		}, true
+	case "google.protobuf.BoolValue":
+		return scalarSchema(protoreflect.BoolKind), true
+	case "google.protobuf.Int32Value":
+		return scalarSchema(protoreflect.Int32Kind), true
+	case "google.protobuf.UInt32Value":
+		return scalarSchema(protoreflect.Uint32Kind), true
+	case "google.protobuf.Int64Value":
+		return scalarSchema(protoreflect.Int64Kind), true
+	case "google.protobuf.UInt64Value":
+		return scalarSchema(protoreflect.Uint64Kind), true
+	case "google.protobuf.FloatValue":
+		return scalarSchema(protoreflect.FloatKind), true
+	case "google.protobuf.DoubleValue":
+		return scalarSchema(protoreflect.DoubleKind), true
+	case "google.protobuf.StringValue":
+		return scalarSchema(protoreflect.StringKind), true
+	case "google.protobuf.BytesValue":
+		return scalarSchema(protoreflect.BytesKind), true
+	}
+	return nil, false
+}
+
+// comments returns the leading comments of the descriptor if the descriptor includes source info.
+// Descriptors compiled into Go code by protoc-gen-go do not include source info, while descriptors
+// provided to protoc plugins do.
+func comments(d protoreflect.Descriptor) string {
+	loc := d.ParentFile().SourceLocations().ByDescriptor(d)
+	lines := strings.Split(strings.TrimSpace(loc.LeadingComments), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isDeprecated returns true if the descriptor has the `deprecated` option set
+func isDeprecated(d protoreflect.Descriptor) bool {
+	switch o := d.Options().(type) {
+	case *descriptorpb.MessageOptions:
+		return o.GetDeprecated()
+	case *descriptorpb.FieldOptions:
+		return o.GetDeprecated()
+	case *descriptorpb.EnumOptions:
+		return o.GetDeprecated()
+	case *descriptorpb.MethodOptions:
+		return o.GetDeprecated()
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"io"
+	"sync"
+)
+
+// bufferSizes are the capacities of the pooled buffers used to read requests and responses and to marshal
+// replies. Buffers are returned to the pool which matches their capacity, such that a buffer which grew to
+// fit a large message is not handed out to read a small message. Buffers larger than the largest size are
+// not pooled, as holding on to them would retain memory long after a burst of large messages.
+var bufferSizes = []int{512, 4 * Kibibyte, 32 * Kibibyte, 256 * Kibibyte, Mebibyte}
+
+var bufferPools = newBufferPools(bufferSizes)
+
+// sizedPool is a pool of buffers with a capacity of at least size
+type sizedPool struct {
+	size int
+	pool sync.Pool
+}
+
+func newBufferPools(sizes []int) []*sizedPool {
+	pools := make([]*sizedPool, len(sizes))
+	for i, size := range sizes {
+		p := &sizedPool{size: size}
+		p.pool.New = func() any {
+			b := make([]byte, 0, p.size)
+			return &b
+		}
+		pools[i] = p
+	}
+	return pools
+}
+
+// getBuffer returns an empty buffer with a capacity of at least size from the pool. If size is unknown
+// (zero or negative) the smallest buffer is returned. The buffer should be returned to the pool via
+// putBuffer() once it is no longer referenced.
+func getBuffer(size int) *[]byte {
+	for _, p := range bufferPools {
+		if size <= p.size {
+			b := p.pool.Get().(*[]byte)
+			*b = (*b)[:0]
+			return b
+		}
+	}
+	b := make([]byte, 0, size)
+	return &b
+}
+
+// putBuffer returns the buffer to the pool which matches the capacity of the buffer
+func putBuffer(b *[]byte) {
+	c := cap(*b)
+	for i := len(bufferPools) - 1; i >= 0; i-- {
+		p := bufferPools[i]
+		if c >= p.size {
+			if i == len(bufferPools)-1 && c > 2*p.size {
+				return
+			}
+			*b = (*b)[:0]
+			p.pool.Put(b)
+			return
+		}
+	}
+}
+
+// readAppend reads from r until EOF appending the data to b, and returns the extended slice. It is
+// identical to io.ReadAll() except the caller provides the buffer.
+func readAppend(b []byte, r io.Reader) ([]byte, error) {
+	for {
+		if len(b) == cap(b) {
+			// Add more capacity (let append pick how much)
+			b = append(b, 0)[:len(b)]
+		}
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return b, err
+		}
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DetailsRateLimitLimit is the v1.Reply.Details key for the maximum number of requests
+	// allowed within the current rate limit window.
+	DetailsRateLimitLimit = "ratelimit.limit"
+	// DetailsRateLimitRemaining is the v1.Reply.Details key for the number of requests
+	// remaining in the current rate limit window.
+	DetailsRateLimitRemaining = "ratelimit.remaining"
+	// DetailsRateLimitReset is the v1.Reply.Details key for the time the current rate limit window
+	// resets, formatted as RFC3339 with nanosecond precision in UTC.
+	DetailsRateLimitReset = "ratelimit.reset"
+
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// RateLimit describes the rate limit imposed on the client by the service.
+type RateLimit struct {
+	// Limit is the maximum number of requests allowed within the rate limit window
+	Limit int64
+	// Remaining is the number of requests remaining in the current rate limit window
+	Remaining int64
+	// Reset is the time the current rate limit window resets, and the client may retry the request
+	Reset time.Time
+}
+
+// Details returns the rate limit as v1.Reply.Details
+func (rl RateLimit) Details() map[string]string {
+	return map[string]string{
+		DetailsRateLimitLimit:     strconv.FormatInt(rl.Limit, 10),
+		DetailsRateLimitRemaining: strconv.FormatInt(rl.Remaining, 10),
+		DetailsRateLimitReset:     rl.Reset.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// SetHeaders sets the IETF RateLimit headers on the response. `RateLimit-Reset` is the number of
+// seconds until the rate limit window resets, rounded up such that clients never retry too early.
+// Services can call SetHeaders on successful responses to inform clients of their remaining quota.
+func (rl RateLimit) SetHeaders(w http.ResponseWriter) {
+	w.Header().Set(HeaderRateLimitLimit, strconv.FormatInt(rl.Limit, 10))
+	w.Header().Set(HeaderRateLimitRemaining, strconv.FormatInt(rl.Remaining, 10))
+	w.Header().Set(HeaderRateLimitReset, strconv.FormatInt(secondsUntil(rl.Reset, time.Now()), 10))
+}
+
+// ReplyTooManyRequests replies to the request with CodeTooManyRequests, including the rate limit in both
+// the v1.Reply.Details and the IETF RateLimit and `Retry-After` headers. Clients use this information to
+// retry the request once the rate limit resets.
+func ReplyTooManyRequests(w http.ResponseWriter, r *http.Request, rl RateLimit, msg string) {
+	ReplyWithCode(w, r, CodeTooManyRequests, rl.Details(), msg)
+}
+
+// setRateLimitHeaders sets the rate limit headers if the details include a rate limit
+func setRateLimitHeaders(w http.ResponseWriter, details map[string]string) {
+	rl, ok := rateLimitFromDetails(details)
+	if !ok {
+		return
+	}
+	rl.SetHeaders(w)
+	w.Header().Set(HeaderRetryAfter, strconv.FormatInt(secondsUntil(rl.Reset, time.Now()), 10))
+}
+
+// rateLimitFromDetails parses the rate limit from v1.Reply.Details
+func rateLimitFromDetails(details map[string]string) (RateLimit, bool) {
+	reset, err := time.Parse(time.RFC3339Nano, details[DetailsRateLimitReset])
+	if err != nil {
+		return RateLimit{}, false
+	}
+	limit, _ := strconv.ParseInt(details[DetailsRateLimitLimit], 10, 64)
+	remaining, _ := strconv.ParseInt(details[DetailsRateLimitRemaining], 10, 64)
+	return RateLimit{Limit: limit, Remaining: remaining, Reset: reset}, true
+}
+
+// rateLimitFromHeaders parses the rate limit from the IETF RateLimit headers
+func rateLimitFromHeaders(h http.Header, now time.Time) (RateLimit, bool) {
+	secs, err := strconv.ParseInt(h.Get(HeaderRateLimitReset), 10, 64)
+	if err != nil {
+		return RateLimit{}, false
+	}
+	limit, _ := strconv.ParseInt(h.Get(HeaderRateLimitLimit), 10, 64)
+	remaining, _ := strconv.ParseInt(h.Get(HeaderRateLimitRemaining), 10, 64)
+	return RateLimit{Limit: limit, Remaining: remaining, Reset: now.Add(time.Duration(secs) * time.Second)}, true
+}
+
+// secondsUntil returns the number of whole seconds until t, rounded up
+func secondsUntil(t time.Time, now time.Time) int64 {
+	d := t.Sub(now)
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
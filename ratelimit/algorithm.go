@@ -0,0 +1,127 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"math"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+)
+
+// State is the rate limit state of a single key which is persisted by the Store
+type State struct {
+	// Count is the number of tokens available for TokenBucket or the number
+	// of requests counted in the current window for SlidingWindow
+	Count float64
+	// Previous is the number of requests counted in the previous window for SlidingWindow
+	Previous float64
+	// Time is the time of the last update for TokenBucket or the start of the current
+	// window for SlidingWindow. A zero Time indicates the State is new.
+	Time time.Time
+}
+
+// Algorithm counts a request against the State provided, returning the resulting RateLimit and
+// true if the request is allowed. The Algorithm is called by the Store while holding a lock on
+// the State and should modify the State in place.
+type Algorithm func(s *State, now time.Time, limit int64, duration time.Duration) (duh.RateLimit, bool)
+
+// TokenBucket is a token bucket Algorithm where the bucket holds up to `limit` tokens and is refilled
+// at a constant rate of `limit` tokens per `duration`. Each request takes a single token from the bucket.
+// TokenBucket allows bursts of up to `limit` requests.
+func TokenBucket(s *State, now time.Time, limit int64, duration time.Duration) (duh.RateLimit, bool) {
+	rate := float64(limit) / float64(duration)
+	if s.Time.IsZero() {
+		s.Count = float64(limit)
+	} else if elapsed := now.Sub(s.Time); elapsed > 0 {
+		s.Count = math.Min(float64(limit), s.Count+float64(elapsed)*rate)
+	}
+	s.Time = now
+
+	if s.Count < 1 {
+		// Reset when the next token is available
+		return duh.RateLimit{
+			Reset:     now.Add(time.Duration(math.Ceil((1 - s.Count) / rate))),
+			Remaining: 0,
+			Limit:     limit,
+		}, false
+	}
+
+	s.Count--
+	// Reset when the bucket is full again
+	return duh.RateLimit{
+		Reset:     now.Add(time.Duration(math.Ceil((float64(limit) - s.Count) / rate))),
+		Remaining: int64(s.Count),
+		Limit:     limit,
+	}, true
+}
+
+// SlidingWindow is a sliding window Algorithm which estimates the number of requests in the sliding window
+// by weighting the count of the previous fixed window by how much it overlaps the sliding window. This
+// avoids the burst of up to twice the limit allowed by fixed windows without storing every request.
+func SlidingWindow(s *State, now time.Time, limit int64, duration time.Duration) (duh.RateLimit, bool) {
+	if s.Time.IsZero() {
+		s.Time = now
+	}
+
+	// Advance the window if the current window has passed
+	if elapsed := now.Sub(s.Time); elapsed >= duration {
+		windows := elapsed / duration
+		if windows == 1 {
+			s.Previous = s.Count
+		} else {
+			s.Previous = 0
+		}
+		s.Count = 0
+		s.Time = s.Time.Add(windows * duration)
+	}
+
+	elapsed := now.Sub(s.Time)
+	weight := 1 - float64(elapsed)/float64(duration)
+	estimate := s.Previous*weight + s.Count
+
+	if estimate+1 > float64(limit) {
+		return duh.RateLimit{
+			Reset:     slidingReset(s, limit, duration),
+			Remaining: 0,
+			Limit:     limit,
+		}, false
+	}
+
+	s.Count++
+	return duh.RateLimit{
+		Reset:     s.Time.Add(duration),
+		Remaining: int64(float64(limit) - (estimate + 1)),
+		Limit:     limit,
+	}, true
+}
+
+// slidingReset returns the time at which the estimated count of the sliding window
+// falls far enough below the limit to allow another request.
+func slidingReset(s *State, limit int64, duration time.Duration) time.Time {
+	available := float64(limit) - s.Count - 1
+	if available >= 0 && s.Previous > 0 {
+		// Wait until enough of the previous window has slid out of the sliding window
+		return s.Time.Add(time.Duration(math.Ceil(float64(duration) * (1 - available/s.Previous))))
+	}
+
+	// The current window is full, wait until enough of the current window slides out
+	// of the sliding window once it becomes the previous window.
+	next := s.Time.Add(duration)
+	if s.Count <= 0 {
+		return next
+	}
+	return next.Add(time.Duration(math.Ceil(float64(duration) * (1 - float64(limit-1)/s.Count))))
+}
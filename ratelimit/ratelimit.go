@@ -0,0 +1,155 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+)
+
+// KeyFunc returns the key which identifies the rate limit bucket the request is counted against.
+// Requests which return the same key share the same rate limit.
+type KeyFunc func(r *http.Request, method string) string
+
+// ByClientIP keys the rate limit by the IP address of the client
+func ByClientIP(r *http.Request, _ string) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ByMethod keys the rate limit by the DUH-RPC method, such that all clients share the rate limit of the method
+func ByMethod(_ *http.Request, method string) string {
+	return method
+}
+
+// ByHeader keys the rate limit by the value of the header provided, IE: `X-API-Key`
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request, _ string) string {
+		return r.Header.Get(name)
+	}
+}
+
+// Compose combines the keys returned by each KeyFunc, such that the rate limit is applied to
+// the combination. For example Compose(ByHeader("X-API-Key"), ByMethod) applies the rate limit
+// to each API key for each method.
+func Compose(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request, method string) string {
+		var b strings.Builder
+		for _, k := range keys {
+			// Prefix each key with its length, such that a key which includes the separator
+			// can not produce the same combination as different keys.
+			key := k(r, method)
+			b.WriteString(strconv.Itoa(len(key)))
+			b.WriteByte(':')
+			b.WriteString(key)
+		}
+		return b.String()
+	}
+}
+
+type Config struct {
+	// Limit is the number of requests allowed within the Duration. (Required)
+	Limit int64
+	// Duration is the rate limit window. (Required)
+	Duration time.Duration
+	// Algorithm is the rate limit algorithm, either TokenBucket or SlidingWindow. (Default: TokenBucket)
+	Algorithm Algorithm
+	// Store holds the state of each rate limit key. (Default: NewMemoryStore())
+	Store Store
+	// Key returns the key the request is rate limited by. Requests with an empty key share
+	// the same rate limit. (Default: ByClientIP)
+	Key KeyFunc
+	// Log is used to log errors returned by the Store. (Default: duh.NoOpLogger)
+	Log duh.StandardLogger
+	// Now returns the current time. (Default: time.Now)
+	Now func() time.Time
+}
+
+// Middleware returns duh.Middleware which limits the rate of requests according to the Config provided.
+// Requests which exceed the rate limit are rejected with duh.CodeTooManyRequests using
+// duh.ReplyTooManyRequests(), allowed requests include the IETF RateLimit headers in the response.
+//
+// If the Store returns an error, the error is logged and the request is allowed, such that an
+// unavailable Store does not result in an outage of the service.
+//
+//	router := duh.NewRouter()
+//	router.Use(ratelimit.Middleware(ratelimit.Config{
+//		Key:      ratelimit.Compose(ratelimit.ByHeader("X-API-Key"), ratelimit.ByMethod),
+//		Algorithm: ratelimit.SlidingWindow,
+//		Duration: time.Minute,
+//		Limit:    100,
+//	}))
+func Middleware(conf Config) duh.Middleware {
+	if conf.Limit <= 0 || conf.Duration <= 0 {
+		panic("ratelimit: Config.Limit and Config.Duration must be greater than zero")
+	}
+	if conf.Algorithm == nil {
+		conf.Algorithm = TokenBucket
+	}
+
+	if conf.Key == nil {
+		conf.Key = ByClientIP
+	}
+	if conf.Log == nil {
+		conf.Log = duh.NoOpLogger{}
+	}
+	if conf.Now == nil {
+		conf.Now = time.Now
+	}
+	if conf.Store == nil {
+		conf.Store = NewMemoryStore()
+	}
+
+	return func(method string, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rl, allowed, err := take(r.Context(), conf, conf.Key(r, method))
+			if err != nil {
+				conf.Log.Error("while updating rate limit store", "method", method, "err", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed {
+				duh.ReplyTooManyRequests(w, r, rl, "rate limit exceeded")
+				return
+			}
+			rl.SetHeaders(w)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// take counts the request against the rate limit of the key provided
+func take(ctx context.Context, conf Config, key string) (duh.RateLimit, bool, error) {
+	var rl duh.RateLimit
+	var allowed bool
+
+	// Expire the state after the window has passed twice, since the sliding window
+	// needs the count from the previous window.
+	now := conf.Now()
+	err := conf.Store.Update(ctx, key, now, 2*conf.Duration, func(s *State) {
+		rl, allowed = conf.Algorithm(s, now, conf.Limit, conf.Duration)
+	})
+	return rl, allowed, err
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/internal/test"
+	"github.com/duh-rpc/duh-go/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var s ratelimit.State
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The bucket starts full, allowing a burst of up to the limit
+	for i := 0; i < 10; i++ {
+		rl, ok := ratelimit.TokenBucket(&s, now, 10, time.Second)
+		require.True(t, ok)
+		assert.Equal(t, int64(9-i), rl.Remaining)
+		assert.Equal(t, int64(10), rl.Limit)
+	}
+
+	rl, ok := ratelimit.TokenBucket(&s, now, 10, time.Second)
+	require.False(t, ok)
+	assert.Equal(t, int64(0), rl.Remaining)
+	// Reset is when the next token becomes available
+	assert.Equal(t, now.Add(100*time.Millisecond), rl.Reset)
+
+	// A single token is refilled after 100ms
+	now = now.Add(100 * time.Millisecond)
+	_, ok = ratelimit.TokenBucket(&s, now, 10, time.Second)
+	require.True(t, ok)
+	_, ok = ratelimit.TokenBucket(&s, now, 10, time.Second)
+	require.False(t, ok)
+
+	// The bucket never holds more than the limit
+	now = now.Add(time.Hour)
+	rl, ok = ratelimit.TokenBucket(&s, now, 10, time.Second)
+	require.True(t, ok)
+	assert.Equal(t, int64(9), rl.Remaining)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	var s ratelimit.State
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 10; i++ {
+		rl, ok := ratelimit.SlidingWindow(&s, now, 10, time.Second)
+		require.True(t, ok)
+		assert.Equal(t, int64(9-i), rl.Remaining)
+	}
+
+	rl, ok := ratelimit.SlidingWindow(&s, now, 10, time.Second)
+	require.False(t, ok)
+	assert.Equal(t, int64(0), rl.Remaining)
+	// The next request is allowed once 10% of the full window has slid out
+	// of the sliding window after the current window ends.
+	assert.Equal(t, now.Add(1100*time.Millisecond), rl.Reset)
+
+	// Half way into the next window, half of the previous window is still counted
+	now = now.Add(1500 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		_, ok = ratelimit.SlidingWindow(&s, now, 10, time.Second)
+		require.True(t, ok)
+	}
+	_, ok = ratelimit.SlidingWindow(&s, now, 10, time.Second)
+	require.False(t, ok)
+
+	// After two windows have passed, the previous counts are forgotten
+	now = now.Add(2 * time.Second)
+	rl, ok = ratelimit.SlidingWindow(&s, now, 10, time.Second)
+	require.True(t, ok)
+	assert.Equal(t, int64(9), rl.Remaining)
+}
+
+func TestMiddleware(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	router := duh.NewRouter()
+	router.Use(ratelimit.Middleware(ratelimit.Config{
+		Key:      ratelimit.Compose(ratelimit.ByHeader("X-API-Key"), ratelimit.ByMethod),
+		Duration: time.Minute,
+		Store:    store,
+		Limit:    2,
+	}))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		duh.Reply(w, r, duh.CodeOK, &test.ErrorsRequest{})
+	}
+	router.HandleFunc("/v1/test.one", handler)
+	router.HandleFunc("/v1/test.two", handler)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	call := func(method, key string) error {
+		req, err := http.NewRequest(http.MethodPost, server.URL+method, nil)
+		require.NoError(t, err)
+		req.Header.Set("X-API-Key", key)
+		return duh.DefaultClient.Do(req, &test.ErrorsRequest{})
+	}
+
+	require.NoError(t, call("/v1/test.one", "key-1"))
+	require.NoError(t, call("/v1/test.one", "key-1"))
+
+	err := call("/v1/test.one", "key-1")
+	var e *duh.ClientError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, duh.CodeTooManyRequests, e.Code())
+	assert.Equal(t, "rate limit exceeded", e.Message())
+	rl, ok := e.RateLimit()
+	require.True(t, ok)
+	assert.Equal(t, int64(2), rl.Limit)
+	assert.Equal(t, int64(0), rl.Remaining)
+	assert.True(t, e.RetryAfter() > 0)
+
+	// Other keys and methods have their own rate limit
+	require.NoError(t, call("/v1/test.one", "key-2"))
+	require.NoError(t, call("/v1/test.two", "key-1"))
+	assert.Equal(t, 3, store.Len())
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Run("zero value", func(t *testing.T) {
+		var store ratelimit.MemoryStore
+		require.NoError(t, store.Update(context.Background(), "key", time.Now(), time.Minute, func(s *ratelimit.State) {
+			s.Count = 1
+		}))
+		assert.Equal(t, 1, store.Len())
+	})
+
+	t.Run("expires using the time provided", func(t *testing.T) {
+		now := time.Now().Add(time.Hour)
+		store := ratelimit.NewMemoryStore()
+
+		update := func(key string) {
+			require.NoError(t, store.Update(context.Background(), key, now, time.Minute,
+				func(s *ratelimit.State) {}))
+		}
+		update("key-1")
+		now = now.Add(30 * time.Second)
+		update("key-2")
+		assert.Equal(t, 2, store.Len())
+
+		// Expired keys are removed once the ttl has passed according to the time provided
+		now = now.Add(2 * time.Minute)
+		update("key-3")
+		assert.Equal(t, 1, store.Len())
+	})
+}
+
+func TestCompose(t *testing.T) {
+	key := ratelimit.Compose(ratelimit.ByHeader("X-Tenant"), ratelimit.ByHeader("X-API-Key"))
+	request := func(tenant, apiKey string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/v1/test.one", nil)
+		r.Header.Set("X-Tenant", tenant)
+		r.Header.Set("X-API-Key", apiKey)
+		return r
+	}
+
+	assert.Equal(t, key(request("a", "b|c"), ""), key(request("a", "b|c"), ""))
+	assert.NotEqual(t, key(request("a|b", "c"), ""), key(request("a", "b|c"), ""))
+	assert.NotEqual(t, key(request("a1:b", ""), ""), key(request("a", "1:b"), ""))
+}
+
+type failStore struct{}
+
+func (failStore) Update(context.Context, string, time.Time, time.Duration, func(*ratelimit.State)) error {
+	return errors.New("store unavailable")
+}
+
+func TestMiddlewareStoreError(t *testing.T) {
+	var called bool
+	h := duh.Chain("/v1/test.one", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(duh.CodeOK)
+	}), ratelimit.Middleware(ratelimit.Config{
+		Duration: time.Minute,
+		Store:    failStore{},
+		Limit:    1,
+	}))
+
+	// Requests are allowed when the store is unavailable
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/test.one", nil))
+	assert.True(t, called)
+	assert.Equal(t, duh.CodeOK, w.Code)
+}
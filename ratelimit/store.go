@@ -0,0 +1,89 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store persists the State of each rate limit key. Implementations could store the State in memory
+// or in a shared database such that multiple instances of a service share the same rate limits.
+type Store interface {
+	// Update atomically calls fn with the State of the key provided and stores the modified State.
+	// If no State exists for the key, fn is called with a zero State. The Store should retain
+	// the State for at least the ttl provided after now, which is the time of the update according
+	// to Config.Now.
+	Update(ctx context.Context, key string, now time.Time, ttl time.Duration, fn func(*State)) error
+}
+
+// MemoryStore is an in memory Store which is suitable for a single instance of a service.
+// The zero value is ready to use.
+type MemoryStore struct {
+	mutex     sync.Mutex
+	states    map[string]*memoryState
+	lastSweep time.Time
+}
+
+type memoryState struct {
+	state   State
+	expires time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns a new in memory Store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) Update(_ context.Context, key string, now time.Time, ttl time.Duration,
+	fn func(*State)) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.states == nil {
+		m.states = make(map[string]*memoryState)
+		m.lastSweep = now
+	}
+
+	s, ok := m.states[key]
+	if !ok || now.After(s.expires) {
+		s = &memoryState{}
+		m.states[key] = s
+	}
+	fn(&s.state)
+	s.expires = now.Add(ttl)
+
+	// Periodically remove expired states, such that keys which are no longer
+	// in use do not accumulate.
+	if now.Sub(m.lastSweep) > ttl {
+		for k, v := range m.states {
+			if now.After(v.expires) {
+				delete(m.states, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	return nil
+}
+
+// Len returns the number of keys held by the store
+func (m *MemoryStore) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.states)
+}
@@ -0,0 +1,110 @@
+package duh_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/internal/test"
+	"github.com/duh-rpc/duh-go/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	var attempts int
+	var reset time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch r.URL.Path {
+		case "/v1/test.limited":
+			if time.Now().Before(reset) {
+				duh.ReplyTooManyRequests(w, r, duh.RateLimit{Limit: 10, Remaining: 0, Reset: reset},
+					"rate limit exceeded")
+				return
+			}
+			rl := duh.RateLimit{Limit: 10, Remaining: 9, Reset: time.Now().Add(time.Second)}
+			rl.SetHeaders(w)
+			duh.Reply(w, r, duh.CodeOK, &test.ErrorsRequest{})
+		case "/v1/test.gateway":
+			// Simulate an API Gateway which imposes its own rate limit
+			w.Header().Set(duh.HeaderRateLimitLimit, "100")
+			w.Header().Set(duh.HeaderRateLimitRemaining, "0")
+			w.Header().Set(duh.HeaderRateLimitReset, "30")
+			w.WriteHeader(duh.CodeTooManyRequests)
+			_, _ = w.Write([]byte("Too Many Requests"))
+		}
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	newRequest := func(path string) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+path, bytes.NewReader(nil))
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("reply includes headers and details", func(t *testing.T) {
+		reset = time.Now().Add(2500 * time.Millisecond)
+		resp, err := duh.DefaultClient.Client.Do(newRequest("/v1/test.limited"))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, duh.CodeTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "10", resp.Header.Get(duh.HeaderRateLimitLimit))
+		assert.Equal(t, "0", resp.Header.Get(duh.HeaderRateLimitRemaining))
+		assert.Equal(t, "3", resp.Header.Get(duh.HeaderRateLimitReset))
+		assert.Equal(t, "3", resp.Header.Get(duh.HeaderRetryAfter))
+	})
+
+	t.Run("client error accessors", func(t *testing.T) {
+		reset = time.Now().Add(2 * time.Second)
+		err := duh.DefaultClient.Do(newRequest("/v1/test.limited"), &test.ErrorsRequest{})
+
+		var e *duh.ClientError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeTooManyRequests, e.Code())
+		assert.Equal(t, "rate limit exceeded", e.Message())
+		assert.Equal(t, reset.UTC().Format(time.RFC3339Nano), e.Details()[duh.DetailsRateLimitReset])
+
+		rl, ok := e.RateLimit()
+		require.True(t, ok)
+		assert.Equal(t, int64(10), rl.Limit)
+		assert.Equal(t, int64(0), rl.Remaining)
+		assert.True(t, reset.Equal(rl.Reset))
+		assert.InDelta(t, time.Until(reset), e.RetryAfter(), float64(100*time.Millisecond))
+	})
+
+	t.Run("rate limit from infrastructure headers", func(t *testing.T) {
+		err := duh.DefaultClient.Do(newRequest("/v1/test.gateway"), &test.ErrorsRequest{})
+
+		var e *duh.ClientError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeTooManyRequests, e.Code())
+		rl, ok := e.RateLimit()
+		require.True(t, ok)
+		assert.Equal(t, int64(100), rl.Limit)
+		assert.InDelta(t, 30*time.Second, e.RetryAfter(), float64(time.Second))
+	})
+
+	t.Run("retry sleeps until reset", func(t *testing.T) {
+		attempts = 0
+		reset = time.Now().Add(300 * time.Millisecond)
+		policy := retry.Policy{
+			Interval: retry.Sleep(5 * time.Second),
+			OnCodes:  retry.RetryableCodes,
+		}
+
+		start := time.Now()
+		err := duh.DefaultClient.DoWithRetry(newRequest("/v1/test.limited"), &test.ErrorsRequest{}, policy)
+		require.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DetailsCorrelationID is the v1.Reply.Details key of the correlation id of a redacted error
+	DetailsCorrelationID = "duh.correlation-id"
+	// DefaultRedactedMessage is the message sent to the client in place of a redacted error
+	DefaultRedactedMessage = "internal service error"
+)
+
+// Redaction configures the reply to errors returned by the service which do not satisfy the Error
+// interface. Such errors are unexpected and may include details the client should not see, such as
+// SQL statements or file paths. When a Redaction is provided via WithRedaction() or ContextWithRedaction(),
+// the error is logged along with a correlation id and the client receives CodeInternalError with the
+// Message and the correlation id in v1.Reply.Details, such that the reply can be matched to the log entry.
+//
+// A ClientError returned by a request the service made to another service is also redacted, as it may
+// include the address of the other service or the transport error. A ClientError which did not originate
+// from the other service, IE: CodeClientError, CodeTransportError or an infrastructure error, is redacted
+// as above. Otherwise, the reply of the other service is sent without the 'http.*' details.
+//
+// Other errors which satisfy the Error interface are not redacted, as the cause of an error created by
+// NewServiceError() or the New<Code> constructors is never sent to the client. Such errors may however
+// send a message which includes the cause, IE: a msg of err.Error().
+type Redaction struct {
+	// Log is used to log the error which was redacted. (Default: NoOpLogger)
+	Log StandardLogger
+	// Message is sent to the client in place of the error. (Default: DefaultRedactedMessage)
+	Message string
+	// CorrelationID returns the id which identifies the redacted error in the log and the reply, IE: the
+	// id provided by the 'X-Request-Id' header. (Default: a random 128-bit hex id)
+	CorrelationID func(r *http.Request) string
+}
+
+type redactionKey struct{}
+
+// ContextWithRedaction returns a new context with the Redaction provided, which is used by ReplyError(),
+// Reply() and StreamWriter.Close() to redact errors which do not satisfy the Error interface.
+func ContextWithRedaction(ctx context.Context, r *Redaction) context.Context {
+	return context.WithValue(ctx, redactionKey{}, r)
+}
+
+// RedactionFromContext returns the Redaction provided to ContextWithRedaction() or nil if none was provided
+func RedactionFromContext(ctx context.Context) *Redaction {
+	r, _ := ctx.Value(redactionKey{}).(*Redaction)
+	return r
+}
+
+// WithRedaction returns Middleware which redacts errors which do not satisfy the Error interface from
+// the replies of every method registered with the Router. See Redaction.
+//
+//	router := duh.NewRouter()
+//	router.Use(duh.WithRedaction(&duh.Redaction{Log: slog.Default()}))
+func WithRedaction(red *Redaction) Middleware {
+	return func(_ string, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ContextWithRedaction(r.Context(), red)))
+		})
+	}
+}
+
+// internalErrorReply returns the reply for an error which does not satisfy the Error interface, redacting
+// the error if a Redaction was provided via the context of the request.
+func internalErrorReply(r *http.Request, err error) *v1.Reply {
+	reply := &v1.Reply{
+		CodeText: CodeText(CodeInternalError),
+		Code:     CodeInternalError,
+		Message:  err.Error(),
+	}
+
+	red := RedactionFromContext(r.Context())
+	if red == nil {
+		return reply
+	}
+
+	log, msg, id := red.Log, red.Message, randomCorrelationID
+	if log == nil {
+		log = NoOpLogger{}
+	}
+	if msg == "" {
+		msg = DefaultRedactedMessage
+	}
+	if red.CorrelationID != nil {
+		id = red.CorrelationID
+	}
+
+	reply.Message = msg
+	reply.Details = map[string]string{DetailsCorrelationID: id(r)}
+	log.Error("redacted internal error from reply", "method", r.URL.Path, "err", err,
+		"correlation-id", reply.Details[DetailsCorrelationID])
+	return reply
+}
+
+// redactClientError returns the redacted reply for a ClientError if a Redaction was provided via the
+// context of the request, or nil if the error is not a ClientError or no Redaction was provided.
+func redactClientError(r *http.Request, err Error) *v1.Reply {
+	e, ok := err.(*ClientError)
+	if !ok || RedactionFromContext(r.Context()) == nil {
+		return nil
+	}
+
+	if e.code == CodeClientError || e.code == CodeTransportError || e.isInfraError {
+		return internalErrorReply(r, e)
+	}
+
+	reply := proto.Clone(e.ProtoMessage()).(*v1.Reply)
+	for _, k := range []string{DetailsHttpCode, DetailsHttpUrl, DetailsHttpMethod, DetailsHttpStatus,
+		DetailsHttpBody} {
+		delete(reply.Details, k)
+	}
+	return reply
+}
+
+// randomCorrelationID returns a random 128-bit hex encoded id
+func randomCorrelationID(_ *http.Request) string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
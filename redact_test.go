@@ -0,0 +1,227 @@
+package duh_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type logEntry struct {
+	msg  string
+	args []any
+}
+
+// recordLogger records the messages logged at the error level
+type recordLogger struct {
+	duh.NoOpLogger
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordLogger) Error(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{msg: msg, args: args})
+}
+
+func (l *recordLogger) Entries() []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]logEntry(nil), l.entries...)
+}
+
+func TestRedaction(t *testing.T) {
+	leak := errors.New("pq: relation \"users\" does not exist")
+	downstream := httptest.NewServer(duh.NewHandler(func(ctx context.Context, req *test.ErrorsRequest,
+		resp *test.ErrorsRequest) error {
+		return duh.NewNotFound("no such user", nil, nil)
+	}, duh.HandlerConfig{}))
+	defer downstream.Close()
+
+	fail := func(ctx context.Context, req *test.ErrorsRequest, resp *test.ErrorsRequest) error {
+		switch req.Case {
+		case "client error":
+			// The request to the other service fails to connect
+			c := test.NewClient(test.ClientConfig{Endpoint: "http://127.0.0.1:1"})
+			return c.TestErrors(ctx, &test.ErrorsRequest{})
+		case "downstream error":
+			c := test.NewClient(test.ClientConfig{Endpoint: downstream.URL})
+			return c.TestErrors(ctx, &test.ErrorsRequest{})
+		case "duh error":
+			return duh.NewNotFound("no such user", leak, nil)
+		case "internal error":
+			return duh.NewInternalError("", leak, nil)
+		case "internal error with verb":
+			return duh.NewInternalError("while fetching user: %w", leak, nil)
+		case "wrapped":
+			return fmt.Errorf("while fetching user: %w", leak)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	newServer := func(mw ...duh.Middleware) *httptest.Server {
+		router := duh.NewRouter()
+		router.Use(mw...)
+		router.Handle("/v1/test.errors", duh.NewHandler(fail, duh.HandlerConfig{}))
+		router.HandleFunc("/v1/test.stream", func(w http.ResponseWriter, r *http.Request) {
+			s, err := duh.NewStreamWriter(w, r)
+			if err != nil {
+				duh.ReplyError(w, r, err)
+				return
+			}
+			_ = s.Send(&test.ErrorsRequest{Case: "item-0"})
+			_ = s.Close(leak)
+		})
+		return httptest.NewServer(router)
+	}
+
+	t.Run("without redaction", func(t *testing.T) {
+		server := newServer()
+		defer server.Close()
+
+		c := test.NewClient(test.ClientConfig{Endpoint: server.URL})
+		err := c.TestErrors(ctx, &test.ErrorsRequest{Case: "wrapped"})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeInternalError, e.Code())
+		assert.Equal(t, "while fetching user: "+leak.Error(), e.Message())
+	})
+
+	t.Run("redacts unknown errors", func(t *testing.T) {
+		log := &recordLogger{}
+		server := newServer(duh.WithRedaction(&duh.Redaction{Log: log}))
+		defer server.Close()
+
+		c := test.NewClient(test.ClientConfig{Endpoint: server.URL})
+		err := c.TestErrors(ctx, &test.ErrorsRequest{Case: "wrapped"})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeInternalError, e.Code())
+		assert.Equal(t, duh.DefaultRedactedMessage, e.Message())
+		assert.NotContains(t, err.Error(), "pq:")
+		id := e.Details()[duh.DetailsCorrelationID]
+		assert.Len(t, id, 32)
+
+		entries := log.Entries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, []any{"method", "/v1/test.errors", "err", fmt.Errorf("while fetching user: %w", leak),
+			"correlation-id", id}, entries[0].args)
+
+		// Each reply has a unique correlation id
+		err = c.TestErrors(ctx, &test.ErrorsRequest{Case: "wrapped"})
+		require.True(t, errors.As(err, &e))
+		assert.NotEqual(t, id, e.Details()[duh.DetailsCorrelationID])
+	})
+
+	t.Run("does not redact DUH errors", func(t *testing.T) {
+		log := &recordLogger{}
+		server := newServer(duh.WithRedaction(&duh.Redaction{Log: log}))
+		defer server.Close()
+
+		c := test.NewClient(test.ClientConfig{Endpoint: server.URL})
+		err := c.TestErrors(ctx, &test.ErrorsRequest{Case: "duh error"})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeNotFound, e.Code())
+		assert.Equal(t, "no such user", e.Message())
+		assert.Empty(t, log.Entries())
+	})
+
+	t.Run("internal errors do not include the cause", func(t *testing.T) {
+		server := newServer(duh.WithRedaction(&duh.Redaction{Log: &recordLogger{}}))
+		defer server.Close()
+
+		c := test.NewClient(test.ClientConfig{Endpoint: server.URL})
+		for _, tc := range []string{"internal error", "internal error with verb"} {
+			err := c.TestErrors(ctx, &test.ErrorsRequest{Case: tc})
+			var e duh.Error
+			require.True(t, errors.As(err, &e), tc)
+			assert.Equal(t, duh.CodeInternalError, e.Code(), tc)
+			assert.Equal(t, duh.CodeText(duh.CodeInternalError), e.Message(), tc)
+			assert.NotContains(t, err.Error(), "pq:", tc)
+		}
+	})
+
+	t.Run("redacts client errors", func(t *testing.T) {
+		log := &recordLogger{}
+		server := newServer(duh.WithRedaction(&duh.Redaction{Log: log}))
+		defer server.Close()
+
+		c := test.NewClient(test.ClientConfig{Endpoint: server.URL})
+		err := c.TestErrors(ctx, &test.ErrorsRequest{Case: "client error"})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeInternalError, e.Code())
+		assert.Equal(t, duh.DefaultRedactedMessage, e.Message())
+		assert.NotContains(t, err.Error(), "127.0.0.1:1")
+		assert.Equal(t, server.URL+"/v1/test.errors", e.Details()[duh.DetailsHttpUrl])
+		assert.Len(t, log.Entries(), 1)
+
+		// The reply of the other service is sent without the details of the request to the other service
+		err = c.TestErrors(ctx, &test.ErrorsRequest{Case: "downstream error"})
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeNotFound, e.Code())
+		assert.Equal(t, "no such user", e.Message())
+		assert.NotContains(t, err.Error(), downstream.URL)
+		assert.Equal(t, server.URL+"/v1/test.errors", e.Details()[duh.DetailsHttpUrl])
+		assert.Len(t, log.Entries(), 1)
+	})
+
+	t.Run("custom message and correlation id", func(t *testing.T) {
+		server := newServer(duh.WithRedaction(&duh.Redaction{
+			Message: "something went wrong",
+			CorrelationID: func(r *http.Request) string {
+				return r.Header.Get("X-Request-Id")
+			},
+		}))
+		defer server.Close()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.errors",
+			strings.NewReader(`{"case": "wrapped"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", duh.ContentTypeJSON)
+		req.Header.Set("X-Request-Id", "request-1")
+
+		err = duh.DefaultClient.Do(req, &test.ErrorsRequest{})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "something went wrong", e.Message())
+		assert.Equal(t, "request-1", e.Details()[duh.DetailsCorrelationID])
+	})
+
+	t.Run("redacts stream trailer", func(t *testing.T) {
+		log := &recordLogger{}
+		server := newServer(duh.WithRedaction(&duh.Redaction{Log: log}))
+		defer server.Close()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.stream", nil)
+		require.NoError(t, err)
+		stream, err := duh.DefaultClient.DoStream(req)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		var m test.ErrorsRequest
+		require.NoError(t, stream.Recv(&m))
+		err = stream.Recv(&m)
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeInternalError, e.Code())
+		assert.Equal(t, duh.DefaultRedactedMessage, e.Message())
+		assert.Equal(t, stream.Trailer().Details[duh.DetailsCorrelationID], e.Details()[duh.DetailsCorrelationID])
+		assert.Len(t, log.Entries(), 1)
+	})
+}
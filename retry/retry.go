@@ -22,6 +22,7 @@ import (
 	"math/rand"
 	"net/http"
 	"slices"
+	"sync"
 	"time"
 )
 
@@ -40,7 +41,7 @@ type BackOff struct {
 func (b BackOff) Next(attempts int) time.Duration {
 	d := time.Duration(float64(b.Min) * math.Pow(b.Factor, float64(attempts)))
 	if b.Rand != nil {
-		d = time.Duration(b.Rand.Float64() * b.Jitter * float64(d))
+		d = time.Duration(randFloat64(b.Rand) * b.Jitter * float64(d))
 	}
 	if d > b.Max {
 		return b.Max
@@ -51,6 +52,16 @@ func (b BackOff) Next(attempts int) time.Duration {
 	return d
 }
 
+// randMu guards BackOff.Rand, as a *rand.Rand is not safe for concurrent use and
+// DefaultBackOff is shared by every Policy which uses it.
+var randMu sync.Mutex
+
+func randFloat64(r *rand.Rand) float64 {
+	randMu.Lock()
+	defer randMu.Unlock()
+	return r.Float64()
+}
+
 var DefaultBackOff = BackOff{
 	Rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
 	Min:    500 * time.Millisecond,
@@ -98,6 +109,8 @@ type Policy struct {
 	Attempts int // 0 for infinite
 }
 
+var _ duh.RetryPolicy = Policy{}
+
 // Twice policy will retry 'twice' if there was an error. Uses the default back off policy
 var Twice = Policy{
 	Interval: DefaultBackOff,
@@ -118,6 +131,17 @@ var OnRetryable = Policy{
 	Attempts: 0,
 }
 
+// On is identical to retry.On() using this Policy, which allows the Policy to be used
+// with duh.Client.DoWithRetry()
+func (p Policy) On(ctx context.Context, operation func(context.Context, int) error) error {
+	return On(ctx, p, operation)
+}
+
+// retryAfter is implemented by errors which know how long to wait before retrying (IE: duh.ClientError)
+type retryAfter interface {
+	RetryAfter() time.Duration
+}
+
 func shouldRetry(err error, policy Policy) bool {
 	if err == nil {
 		panic("err cannot be nil")
@@ -134,6 +158,9 @@ func shouldRetry(err error, policy Policy) bool {
 	return false
 }
 
+// On calls the operation provided until it succeeds, the Policy gives up or the context is cancelled.
+// If the error returned by the operation provides a RetryAfter() duration (IE: duh.ClientError from a
+// 429 reply) On will wait the duration requested by the server instead of the Policy.Interval.
 func On(ctx context.Context, p Policy, operation func(context.Context, int) error) error {
 	attempt := 1
 	if p.Interval == nil {
@@ -150,12 +177,24 @@ func On(ctx context.Context, p Policy, operation func(context.Context, int) erro
 				return err
 			}
 
-			if shouldRetry(err, p) {
-				time.Sleep(p.Interval.Next(p.Attempts))
-				attempt++
-			} else {
+			if !shouldRetry(err, p) {
 				return err
 			}
+
+			interval := p.Interval.Next(p.Attempts)
+			var ra retryAfter
+			if errors.As(err, &ra) && ra.RetryAfter() > 0 {
+				interval = ra.RetryAfter()
+			}
+
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			attempt++
 		}
 	}
 }
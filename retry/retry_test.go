@@ -23,6 +23,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
 	"math"
+	"math/rand"
 	"sync"
 	"testing"
 	"time"
@@ -171,6 +172,25 @@ func TestRetry(t *testing.T) {
 	})
 }
 
+func TestBackOffConcurrent(t *testing.T) {
+	b := retry.BackOff{Min: time.Millisecond, Max: time.Second, Factor: 2, Jitter: 0.2,
+		Rand: rand.New(rand.NewSource(1))}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				d := b.Next(2)
+				assert.GreaterOrEqual(t, d, b.Min)
+				assert.LessOrEqual(t, d, b.Max)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 type testError struct {
 	code int
 }
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Router routes DUH-RPC method calls to the handler registered for the method. Since DUH-RPC methods are
+// always in the form `/<version>/<subject>.<method>` there is no need for a fancy router with pattern matching,
+// instead methods are matched by an exact string match of the request path which is fast and simple.
+//
+// Router will reply with CodeBadRequest if the request is not a POST, and CodeNotImplemented if the method
+// requested has no registered handler.
+//
+// Middleware added via Use() is applied to every method registered with the Router. Middleware only
+// runs for registered methods, the Router rejects non POST requests and unknown methods before any
+// middleware is called.
+//
+// Handlers should be registered before the Router begins serving requests, Router is not safe for
+// registering new handlers while concurrently serving requests.
+type Router struct {
+	methods    map[string]http.Handler
+	infos      map[string]MethodInfo
+	middleware []Middleware
+}
+
+var _ http.Handler = (*Router)(nil)
+
+// NewRouter returns a new Router with no methods registered
+func NewRouter() *Router {
+	return &Router{
+		methods: make(map[string]http.Handler),
+		infos:   make(map[string]MethodInfo),
+	}
+}
+
+// Use adds middleware which is applied to all methods registered with the Router. Middleware is applied
+// in the order it was added, such that the first middleware added is the first to see the request.
+// Use panics if called after a method has been registered, as the middleware would not apply to
+// methods already registered.
+func (r *Router) Use(mw ...Middleware) {
+	if len(r.methods) != 0 {
+		panic("duh: all middleware must be added before registering methods with the Router")
+	}
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Handle registers the handler for the given DUH-RPC method path, for example `/v1/say.hello`.
+// Handle panics if the method path is not a valid DUH-RPC method or if a handler is already
+// registered for the method. If the handler implements Describer, the description of the method
+// is recorded and available via MethodInfos().
+func (r *Router) Handle(method string, h http.Handler) {
+	if h == nil {
+		panic("duh: nil handler for method " + method)
+	}
+	if err := validMethod(method); err != nil {
+		panic(fmt.Sprintf("duh: %s", err))
+	}
+	if r.methods == nil {
+		r.methods = make(map[string]http.Handler)
+		r.infos = make(map[string]MethodInfo)
+	}
+	if _, ok := r.methods[method]; ok {
+		panic("duh: multiple registrations for method " + method)
+	}
+
+	var info MethodInfo
+	if d, ok := h.(Describer); ok {
+		info = d.Describe()
+	}
+	info.Path = method
+	r.infos[method] = info
+	r.methods[method] = Chain(method, h, r.middleware...)
+}
+
+// HandleFunc registers the handler function for the given DUH-RPC method path.
+func (r *Router) HandleFunc(method string, h func(http.ResponseWriter, *http.Request)) {
+	if h == nil {
+		panic("duh: nil handler for method " + method)
+	}
+	r.Handle(method, http.HandlerFunc(h))
+}
+
+// Methods returns a sorted list of all the method paths registered with the Router
+func (r *Router) Methods() []string {
+	methods := make([]string, 0, len(r.methods))
+	for m := range r.methods {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// MethodInfos returns the description of all the methods registered with the Router sorted by path. Methods
+// registered with a handler which does not implement Describer only include the path of the method.
+func (r *Router) MethodInfos() []MethodInfo {
+	infos := make([]MethodInfo, 0, len(r.infos))
+	for _, m := range r.Methods() {
+		infos = append(infos, r.infos[m])
+	}
+	return infos
+}
+
+// ServeHTTP dispatches the request to the handler registered for the request path.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		ReplyWithCode(w, req, CodeBadRequest, nil,
+			fmt.Sprintf("http method '%s' not allowed; only POST", req.Method))
+		return
+	}
+
+	h, ok := r.methods[req.URL.Path]
+	if !ok {
+		ReplyWithCode(w, req, CodeNotImplemented, nil, "no such method; "+req.URL.Path)
+		return
+	}
+	h.ServeHTTP(w, req)
+}
+
+// validMethod returns an error if the method is not in the form `/<version>/<subject>.<method>`
+func validMethod(method string) error {
+	parts := strings.Split(method, "/")
+	if len(parts) < 3 || parts[0] != "" {
+		return fmt.Errorf("invalid method '%s'; expected the form '/<version>/<subject>.<method>'", method)
+	}
+	for _, p := range parts[1:] {
+		if p == "" {
+			return fmt.Errorf("invalid method '%s'; contains an empty path segment", method)
+		}
+	}
+	last := parts[len(parts)-1]
+	if i := strings.LastIndex(last, "."); i <= 0 || i == len(last)-1 {
+		return fmt.Errorf("invalid method '%s'; expected the form '/<version>/<subject>.<method>'", method)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package duh_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/duh-rpc/duh-go"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	json "google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestRouter(t *testing.T) {
+	router := duh.NewRouter()
+	router.HandleFunc("/v1/say.hello", func(w http.ResponseWriter, r *http.Request) {
+		duh.ReplyWithCode(w, r, duh.CodeOK, nil, "hello")
+	})
+	router.HandleFunc("/v1/admin/users.create", func(w http.ResponseWriter, r *http.Request) {
+		duh.ReplyWithCode(w, r, duh.CodeOK, nil, "created")
+	})
+
+	assert.Equal(t, []string{"/v1/admin/users.create", "/v1/say.hello"}, router.Methods())
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		path   string
+		msg    string
+		code   int
+	}{
+		{
+			name:   "registered method",
+			method: http.MethodPost,
+			path:   "/v1/say.hello",
+			msg:    "hello",
+			code:   duh.CodeOK,
+		},
+		{
+			name:   "registered method with problem domain",
+			method: http.MethodPost,
+			path:   "/v1/admin/users.create",
+			msg:    "created",
+			code:   duh.CodeOK,
+		},
+		{
+			name:   "only POST is allowed",
+			method: http.MethodGet,
+			path:   "/v1/say.hello",
+			msg:    "http method 'GET' not allowed; only POST",
+			code:   duh.CodeBadRequest,
+		},
+		{
+			name:   "unknown method",
+			method: http.MethodPost,
+			path:   "/v1/say.goodbye",
+			msg:    "no such method; /v1/say.goodbye",
+			code:   duh.CodeNotImplemented,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, bytes.NewReader(nil)))
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, duh.ContentTypeJSON, w.Header().Get("Content-Type"))
+
+			var reply v1.Reply
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reply))
+			assert.Equal(t, tt.msg, reply.Message)
+		})
+	}
+
+	t.Run("invalid registrations", func(t *testing.T) {
+		noop := func(w http.ResponseWriter, r *http.Request) {}
+		assert.Panics(t, func() { router.HandleFunc("/v1/say.hello", noop) })
+		assert.Panics(t, func() { router.HandleFunc("v1/say.hello", noop) })
+		assert.Panics(t, func() { router.HandleFunc("/say.hello", noop) })
+		assert.Panics(t, func() { router.HandleFunc("/v1/say", noop) })
+		assert.Panics(t, func() { router.HandleFunc("/v1/say.", noop) })
+		assert.Panics(t, func() { router.HandleFunc("/v1//say.hello", noop) })
+	})
+}
@@ -15,11 +15,9 @@ limitations under the License.
 package duh
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	v1 "github.com/duh-rpc/duh-go/proto/v1"
-	json "google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"io"
 	"net/http"
@@ -34,52 +32,78 @@ const (
 )
 
 var (
+	// SupportedMimeTypes are the content types supported by DefaultCodecs
+	//
+	// Deprecated: Use DefaultCodecs.ContentTypes() or Codecs.ContentTypes()
 	SupportedMimeTypes = []string{ContentTypeJSON, ContentTypeProtoBuf}
 )
 
 // ReadRequest reads the given http.Request body []byte into the given proto.Message.
 // The provided message must be mutable (e.g., a non-nil pointer to a message).
 // It also handles content negotiation via the 'Content-Type' header provided in the http.Request headers
+// using the codecs returned by CodecsFromContext() and decompresses the body according to the
+// 'Content-Encoding' header using the Compression returned by CompressionFromContext(). The limit
+// applies to the decompressed body.
 func ReadRequest(r *http.Request, m proto.Message, limit int64) error {
-	var b bytes.Buffer
-	body := r.Body
+	// The limit applies to the decompressed body, such that decompression bombs are rejected
+	body, err := decompress(CompressionFromContext(r.Context()), r.Header.Get("Content-Encoding"),
+		r.Body, CodeClientContentError)
+	if err != nil {
+		_ = r.Body.Close()
+		return requestBodyError(err)
+	}
 	defer func() { _ = body.Close() }()
 
 	if limit > 0 {
 		body = NewLimitReader(body, limit)
 	}
 
-	_, err := io.Copy(&b, body)
+	// Use the Content-Length as a hint to the size of the buffer needed to read the body
+	size := r.ContentLength
+	if limit > 0 && size > limit {
+		size = limit
+	}
+	buf := getBuffer(int(size))
+	defer putBuffer(buf)
+
+	b, err := readAppend(*buf, body)
+	*buf = b
 	if err != nil {
-		var e Error
-		if errors.As(err, &e) {
-			return NewServiceError(e.Code(), fmt.Sprintf("request body %s", e.Message()), nil, nil)
-		}
-		return NewServiceError(CodeTransportError, "", err, nil)
+		return requestBodyError(err)
 	}
 
 	// Ignore multiple mime types separated by comma ',' or mime type parameters separated by semicolon ';'
 	mimeType := TrimSuffix(r.Header.Get("Content-Type"), ";,")
 
-	switch strings.TrimSpace(strings.ToLower(mimeType)) {
-	case "", "*/*", "application/*", ContentTypeJSON:
-		if err := json.Unmarshal(b.Bytes(), m); err != nil {
-			return NewServiceError(CodeClientContentError, "", err, nil)
-		}
-		return nil
-	case ContentTypeProtoBuf:
-		if err := proto.Unmarshal(b.Bytes(), m); err != nil {
-			return NewServiceError(CodeClientContentError, "", err, nil)
-		}
-		return nil
+	codec, ok := CodecsFromContext(r.Context()).Lookup(mimeType)
+	if !ok {
+		return NewServiceError(CodeClientContentError,
+			fmt.Sprintf("Content-Type header '%s' is invalid format or unrecognized content type",
+				r.Header.Get("Content-Type")), nil, nil)
+	}
+
+	if err := unmarshalPooled(codec, b, m); err != nil {
+		// The parser error describes what is wrong with the request, such that it is sent to the client
+		return NewServiceError(CodeClientContentError, err.Error(), err, nil)
+	}
+	return nil
+}
+
+// requestBodyError returns an error which describes the failure to read the request body
+func requestBodyError(err error) error {
+	var e Error
+	if errors.As(err, &e) {
+		return NewServiceError(e.Code(), fmt.Sprintf("request body %s", e.Message()), nil, nil)
 	}
-	return NewServiceError(CodeClientContentError, "",
-		fmt.Errorf("Content-Type header '%s' is invalid format or unrecognized content type",
-			r.Header.Get("Content-Type")), nil)
+	return NewServiceError(CodeTransportError, "while reading request body", err, nil)
 }
 
-// ReplyWithCode replies to the request with the specified message and status code
+// ReplyWithCode replies to the request with the specified message and status code. If the code is
+// CodeTooManyRequests and the details include a RateLimit, the RateLimit headers are also set.
 func ReplyWithCode(w http.ResponseWriter, r *http.Request, code int, details map[string]string, msg string) {
+	if code == CodeTooManyRequests {
+		setRateLimitHeaders(w, details)
+	}
 	Reply(w, r, code, &v1.Reply{
 		CodeText: CodeText(code),
 		Code:     int32(code),
@@ -90,54 +114,171 @@ func ReplyWithCode(w http.ResponseWriter, r *http.Request, code int, details map
 
 // ReplyError replies to the request with the error provided. If 'err' satisfies the Error interface,
 // then it will return the code and message provided by the Error. If 'err' does not satisfy the Error
-// it will then return a status of CodeInternalError with the err.Reply() as the message.
+// it will then return a status of CodeInternalError with the err.Error() as the message, or the redacted
+// message if a Redaction was provided via WithRedaction().
 func ReplyError(w http.ResponseWriter, r *http.Request, err error) {
 	var re Error
 	if errors.As(err, &re) {
+		if re.Code() == CodeTooManyRequests {
+			setRateLimitHeaders(w, re.Details())
+		}
+		if reply := redactClientError(r, re); reply != nil {
+			Reply(w, r, int(reply.Code), reply)
+			return
+		}
 		Reply(w, r, re.Code(), re.ProtoMessage())
 		return
 	}
 	// If err has no Error in the error chain, then reply with CodeInternalError and the message
-	// provided.
-	ReplyWithCode(w, r, CodeInternalError, nil, err.Error())
+	// provided, unless the error should be redacted. See Redaction
+	Reply(w, r, CodeInternalError, internalErrorReply(r, err))
 }
 
 // Reply responds to a request with the specified protobuf message and status code.
-// Reply() provides content negotiation for protobuf if the request has the 'Accept' header set.
-// If no 'Accept' header was provided, Reply() will marshall the proto.Message into JSON.
+// Reply() provides content negotiation via the codecs returned by CodecsFromContext() if the request has the
+// 'Accept' header set, using the Negotiation of the codecs (See Codecs.SetNegotiation()). If no 'Accept' header
+// was provided, Reply() will marshall the proto.Message into JSON.
+// If the request has the 'Accept-Encoding' header set, replies larger than Compression.MinSize are compressed
+// using the Compression returned by CompressionFromContext().
 func Reply(w http.ResponseWriter, r *http.Request, code int, resp proto.Message) {
-	// Ignore multiple mime types separated by comma ',' or mime type parameters separated by semicolon ';'
-	mimeType := TrimSuffix(r.Header.Get("Accept"), ";,")
-
-	switch strings.TrimSpace(strings.ToLower(mimeType)) {
-	case "", "*/*", "application/*", ContentTypeJSON:
-		b, err := json.Marshal(resp)
-		if err != nil {
-			// TODO: This should be logged and not returned to the client, we need to define a logger
-			ReplyWithCode(w, r, CodeInternalError, nil, err.Error())
-			return
+	mimeType := r.Header.Get("Accept")
+
+	codecs := CodecsFromContext(r.Context())
+	codec, ok := codecs.Negotiate(mimeType)
+	if !ok {
+		if codecs.Negotiation() == NegotiateStrict {
+			// Only the first mime type without parameters was considered
+			mimeType = TrimSuffix(mimeType, ";,")
 		}
-		w.Header().Set("Content-Type", ContentTypeJSON)
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.WriteHeader(code)
-		_, _ = w.Write(b)
-	case ContentTypeProtoBuf:
-		b, err := proto.Marshal(resp)
-		if err != nil {
-			// TODO: This should be logged and not returned to the client, we need to define a logger
-			ReplyWithCode(w, r, CodeInternalError, nil, err.Error())
+		replyJSON(w, CodeClientContentError, &v1.Reply{
+			CodeText: CodeText(CodeClientContentError),
+			Code:     CodeClientContentError,
+			Message: fmt.Sprintf("Accept header '%s' is invalid format or unrecognized content type, "+
+				"only [%s] are supported by this method", mimeType, strings.Join(codecs.ContentTypes(), ",")),
+		})
+		return
+	}
+
+	// The encoded size of the message is a hint to the size of the buffer needed for any codec
+	buf := getBuffer(proto.Size(resp))
+	defer putBuffer(buf)
+
+	b, err := marshalAppend(replyCodec(codec, resp), *buf, resp)
+	*buf = b
+	if err != nil {
+		replyJSON(w, CodeInternalError, internalErrorReply(r, err))
+		return
+	}
+
+	c := CompressionFromContext(r.Context())
+	if len(c.Encoders) != 0 {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if e, ok := c.negotiate(r.Header.Get("Accept-Encoding")); ok && len(b) >= c.MinSize {
+		cbuf := getBuffer(len(b))
+		defer putBuffer(cbuf)
+
+		if compressed, err := compress(e, *cbuf, b); err == nil {
+			w.Header().Set("Content-Encoding", e.Encoding())
+			*cbuf = compressed
+			b = compressed
+		}
+	}
+
+	w.Header().Set("Content-Type", codec.ContentType())
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
+
+// replyJSON replies with the v1.Reply encoded by JSONCodec without content negotiation. It is used when
+// the negotiated codec can not be used, as calling Reply() again could fail in the same way.
+func replyJSON(w http.ResponseWriter, code int, reply *v1.Reply) {
+	b, err := JSONCodec{}.Marshal(reply)
+	if err != nil {
+		// Only possible if the message is not valid UTF-8
+		b = []byte(fmt.Sprintf(`{"code":%d,"code_text":"%s"}`, code, CodeText(code)))
+	}
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
+
+// ReadOctetStream returns the request body as an un-buffered stream of bytes. The stream returned will
+// return ErrDataLimitExceeded if more than 'limit' bytes are read from the request body. If the request
+// `Content-Type` is not `application/octet-stream` then it returns an error with CodeClientContentError.
+// It is the callers responsibility to close the returned io.ReadCloser.
+func ReadOctetStream(r *http.Request, limit int64) (io.ReadCloser, error) {
+	mimeType := TrimSuffix(r.Header.Get("Content-Type"), ";,")
+	if strings.TrimSpace(strings.ToLower(mimeType)) != ContentOctetStream {
+		return nil, NewServiceError(CodeClientContentError,
+			fmt.Sprintf("Content-Type header '%s' is invalid format or unrecognized content type, "+
+				"only [%s] is supported by this method", r.Header.Get("Content-Type"), ContentOctetStream), nil, nil)
+	}
+
+	if limit > 0 {
+		return NewLimitReader(r.Body, limit), nil
+	}
+	return r.Body, nil
+}
+
+// ReplyOctetStream responds to a request by streaming the contents of the io.Reader provided to the client
+// with the `application/octet-stream` content type and specified status code. If reading the first chunk
+// from the io.Reader fails before the headers are sent, ReplyOctetStream replies with the error via ReplyError().
+// Each chunk read from the io.Reader is flushed to the client, such that live sources are streamed as they
+// produce data rather than buffered.
+//
+// Once headers are sent, it is no longer possible to inform the client of an error via a v1.Reply, as such
+// any error reading from the io.Reader after the headers are sent will abort the response, such that the
+// client doesn't mistake a partial stream for a complete one.
+func ReplyOctetStream(w http.ResponseWriter, r *http.Request, code int, body io.Reader) {
+	buf := make([]byte, 32*Kibibyte)
+
+	// Read the first chunk before writing the headers, such that we can reply with a proper
+	// v1.Reply if the stream fails right away. Only a single Read is made, such that slow or
+	// live readers are streamed to the client as soon as data is available.
+	n, err := body.Read(buf)
+	if err != nil && !errors.Is(err, io.EOF) {
+		ReplyError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentOctetStream)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+
+	fw := &flushWriter{w: w, rc: http.NewResponseController(w)}
+	if n != 0 {
+		if _, err := fw.Write(buf[:n]); err != nil {
 			return
 		}
-		w.Header().Set("Content-Type", ContentTypeProtoBuf)
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.WriteHeader(code)
-		_, _ = w.Write(b)
-	default:
-		r.Header.Set("Accept", ContentTypeJSON)
-		ReplyWithCode(w, r, CodeClientContentError, nil, fmt.Sprintf("Accept header '%s' is invalid format "+
-			"or unrecognized content type, only [%s] are supported by this method",
-			mimeType, strings.Join(SupportedMimeTypes, ",")))
 	}
+	if errors.Is(err, io.EOF) {
+		return
+	}
+
+	if _, err := io.CopyBuffer(fw, body, buf); err != nil {
+		// Abort the response, so the client knows the stream is incomplete
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// flushWriter flushes every write to the client, such that data is streamed as it becomes available
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if err := f.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return n, err
+	}
+	return n, nil
 }
 
 // TrimSuffix trims everything after the first separator is found
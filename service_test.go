@@ -0,0 +1,109 @@
+package duh_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, duh.NewServiceError(duh.CodeNotFound, "no such file", nil, nil)
+}
+
+func TestOctetStream(t *testing.T) {
+	live, liveWriter := io.Pipe()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/files.echo":
+			body, err := duh.ReadOctetStream(r, 10*duh.Bytes)
+			if err != nil {
+				duh.ReplyError(w, r, err)
+				return
+			}
+			defer func() { _ = body.Close() }()
+			duh.ReplyOctetStream(w, r, duh.CodeOK, body)
+		case "/v1/files.missing":
+			duh.ReplyOctetStream(w, r, duh.CodeOK, errReader{})
+		case "/v1/files.live":
+			duh.ReplyOctetStream(w, r, duh.CodeOK, live)
+		}
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	do := func(path, contentType string, payload []byte) (io.ReadCloser, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+path, bytes.NewReader(payload))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		return duh.DefaultClient.DoOctetStream(req)
+	}
+
+	t.Run("echo", func(t *testing.T) {
+		body, err := do("/v1/files.echo", duh.ContentOctetStream, []byte("0123456789"))
+		require.NoError(t, err)
+		defer func() { _ = body.Close() }()
+		b, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, "0123456789", string(b))
+	})
+
+	t.Run("exceeds limit after headers", func(t *testing.T) {
+		// The first chunk is sent before the limit is exceeded, as such the response is aborted
+		body, err := do("/v1/files.echo", duh.ContentOctetStream, []byte("more than ten bytes"))
+		require.NoError(t, err)
+		defer func() { _ = body.Close() }()
+		_, err = io.ReadAll(body)
+		assert.Error(t, err)
+	})
+
+	t.Run("streams live readers", func(t *testing.T) {
+		go func() { _, _ = liveWriter.Write([]byte("first")) }()
+
+		body, err := do("/v1/files.live", duh.ContentOctetStream, nil)
+		require.NoError(t, err)
+		defer func() { _ = body.Close() }()
+
+		// The first chunk is received before the reader has ended
+		b := make([]byte, 5)
+		_, err = io.ReadFull(body, b)
+		require.NoError(t, err)
+		assert.Equal(t, "first", string(b))
+
+		go func() {
+			_, _ = liveWriter.Write([]byte("second"))
+			_ = liveWriter.Close()
+		}()
+		b, err = io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, "second", string(b))
+	})
+
+	t.Run("wrong content type", func(t *testing.T) {
+		_, err := do("/v1/files.echo", duh.ContentTypeJSON, []byte("{}"))
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientContentError, e.Code())
+		assert.True(t, strings.HasPrefix(e.Message(), "Content-Type header 'application/json' is invalid"))
+	})
+
+	t.Run("stream error before headers", func(t *testing.T) {
+		_, err := do("/v1/files.missing", duh.ContentOctetStream, nil)
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeNotFound, e.Code())
+		assert.Equal(t, "no such file", e.Message())
+	})
+}
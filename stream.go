@@ -0,0 +1,532 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ContentTypeProtoBufStream = "application/duh-stream+protobuf"
+	ContentTypeJSONStream     = "application/duh-stream+json"
+)
+
+// streamPrefix is the prefix of all stream content types, the suffix is the subtype of the
+// codec used to encode each message in the stream. IE: `application/duh-stream+json`
+const streamPrefix = "application/duh-stream+"
+
+// A stream is a sequence of frames, each frame is a single flags byte followed by the length of the
+// payload as a 4 byte big endian unsigned integer, followed by the payload.
+//
+//	+-------+------------------+-----------------+
+//	| flags | length (4 bytes) | payload         |
+//	+-------+------------------+-----------------+
+//
+// The payload of a message frame is a single message encoded as JSON or protobuf depending on the content
+// type of the stream. The stream always ends with a trailer frame whose payload is a v1.Reply which reports
+// the success or failure of the method call.
+const (
+	frameMessage    byte = 0x00
+	frameTrailer    byte = 0x01
+	frameHeaderSize      = 5
+)
+
+// writeFrame writes a single frame with the flags and payload provided
+func writeFrame(w io.Writer, flags byte, payload []byte) error {
+	if uint64(len(payload)) > math.MaxUint32 {
+		return fmt.Errorf("frame payload of %d bytes exceeds the maximum frame size", len(payload))
+	}
+	var hdr [frameHeaderSize]byte
+	hdr[0] = flags
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(payload)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readFrame reads a single frame, returning ErrDataLimitExceeded if the payload exceeds the limit provided.
+// Returns io.EOF if the stream ended cleanly before the frame and io.ErrUnexpectedEOF if the stream
+// ended in the middle of a frame.
+func readFrame(r io.Reader, limit int64) (byte, []byte, error) {
+	var hdr [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+
+	length := int64(binary.BigEndian.Uint32(hdr[1:]))
+	body := io.NopCloser(io.LimitReader(r, length))
+	if limit > 0 {
+		body = NewLimitReader(body, limit)
+	}
+
+	payload, err := io.ReadAll(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if int64(len(payload)) != length {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return hdr[0], payload, nil
+}
+
+// StreamContentType returns the stream content type for the codec content type provided,
+// IE: `application/msgpack` returns `application/duh-stream+msgpack`
+func StreamContentType(contentType string) string {
+	_, subtype, _ := strings.Cut(contentType, "/")
+	return streamPrefix + subtype
+}
+
+// streamCodec returns the Codec which encodes each message of the stream content type provided
+func streamCodec(codecs *Codecs, contentType string) (Codec, bool) {
+	subtype, ok := strings.CutPrefix(strings.TrimSpace(strings.ToLower(contentType)), streamPrefix)
+	if !ok || subtype == "" {
+		return nil, false
+	}
+	return codecs.Lookup("application/" + subtype)
+}
+
+// streamContentTypes returns the stream content types supported by the codecs provided
+func streamContentTypes(codecs *Codecs) string {
+	var types []string
+	for _, ct := range codecs.ContentTypes() {
+		if strings.HasPrefix(ct, "application/") {
+			types = append(types, StreamContentType(ct))
+		}
+	}
+	return strings.Join(types, ",")
+}
+
+// StreamWriter streams many messages to the client in a single response.
+type StreamWriter struct {
+	w           http.ResponseWriter
+	r           *http.Request
+	rc          *http.ResponseController
+	codec       Codec
+	contentType string
+	started     bool
+	closed      bool
+}
+
+// NewStreamWriter returns a StreamWriter which streams messages to the client in the content type requested
+// by the 'Accept' header, which must be a stream content type of one of the codecs returned by CodecsFromContext(),
+// IE: ContentTypeJSONStream or ContentTypeProtoBufStream. If the 'Accept' header is not
+// supported, NewStreamWriter returns an error with CodeClientContentError which should be returned to the
+// client via ReplyError().
+//
+// The caller MUST call Close() once the method call is complete, such that the stream is terminated
+// with a trailer which informs the client of the success or failure of the method call.
+//
+//	s, err := duh.NewStreamWriter(w, r)
+//	if err != nil {
+//		duh.ReplyError(w, r, err)
+//		return
+//	}
+//	for _, item := range items {
+//		if err := s.Send(item); err != nil {
+//			_ = s.Close(err)
+//			return
+//		}
+//	}
+//	_ = s.Close(nil)
+func NewStreamWriter(w http.ResponseWriter, r *http.Request) (*StreamWriter, error) {
+	mimeType := strings.TrimSpace(strings.ToLower(TrimSuffix(r.Header.Get("Accept"), ";,")))
+	codecs := CodecsFromContext(r.Context())
+	codec, ok := streamCodec(codecs, mimeType)
+	if !ok {
+		return nil, NewServiceError(CodeClientContentError,
+			fmt.Sprintf("Accept header '%s' is invalid format or unrecognized content type, "+
+				"only [%s] are supported by this method", r.Header.Get("Accept"),
+				streamContentTypes(codecs)), nil, nil)
+	}
+
+	return &StreamWriter{
+		rc:          http.NewResponseController(w),
+		contentType: mimeType,
+		codec:       codec,
+		w:           w,
+		r:           r,
+	}, nil
+}
+
+// Send marshals and sends the message to the client. The message is flushed to the client
+// before Send returns. Returns an error with CodeTransportError if the client went away.
+func (s *StreamWriter) Send(m proto.Message) error {
+	if s.closed {
+		return errors.New("send on a closed stream")
+	}
+	if err := s.r.Context().Err(); err != nil {
+		return NewServiceError(CodeTransportError, "request context is done", err, nil)
+	}
+
+	b, err := s.codec.Marshal(m)
+	if err != nil {
+		return NewServiceError(CodeInternalError, "while marshalling stream message", err, nil)
+	}
+	s.start()
+	return s.write(frameMessage, b)
+}
+
+// Close terminates the stream with a trailer. If err is nil the trailer reports success with CodeOK,
+// else the trailer reports the error in the same way ReplyError() would. If no messages have been
+// sent, and err is not nil, the error is returned to the client as a normal reply via ReplyError(),
+// such that the client can handle the error as it would any other method call.
+func (s *StreamWriter) Close(err error) error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	if !s.started && err != nil {
+		// Reply with the non-stream equivalent of the requested content type
+		s.r.Header.Set("Accept", s.codec.ContentType())
+		ReplyError(s.w, s.r, err)
+		return nil
+	}
+	s.start()
+
+	reply := &v1.Reply{Code: CodeOK, CodeText: CodeText(CodeOK)}
+	if err != nil {
+		var re Error
+		if errors.As(err, &re) {
+			if reply = redactClientError(s.r, re); reply == nil {
+				reply = re.ProtoMessage().(*v1.Reply)
+			}
+		} else {
+			reply = internalErrorReply(s.r, err)
+		}
+	}
+
+	b, mErr := replyCodec(s.codec, reply).Marshal(reply)
+	if mErr != nil {
+		return NewServiceError(CodeInternalError, "while marshalling stream trailer", mErr, nil)
+	}
+	return s.write(frameTrailer, b)
+}
+
+// start writes the response headers if they have not already been written
+func (s *StreamWriter) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", s.contentType)
+	s.w.Header().Set("X-Content-Type-Options", "nosniff")
+	s.w.WriteHeader(CodeOK)
+}
+
+func (s *StreamWriter) write(flags byte, b []byte) error {
+	if err := writeFrame(s.w, flags, b); err != nil {
+		return NewServiceError(CodeTransportError, "while writing stream frame", err, nil)
+	}
+	if err := s.rc.Flush(); err != nil {
+		return NewServiceError(CodeTransportError, "while flushing stream frame", err, nil)
+	}
+	return nil
+}
+
+// StreamReader reads a stream of messages sent by the server. Use Client.DoStream() to create a StreamReader.
+type StreamReader struct {
+	req     *http.Request
+	resp    *http.Response
+	body    *bufio.Reader
+	codec   Codec
+	limit   int64
+	trailer *v1.Reply
+	err     error
+}
+
+// Recv reads the next message from the stream into the proto.Message provided. Recv returns io.EOF once
+// the server has terminated the stream successfully. If the server terminated the stream with an error,
+// Recv returns the error as a *ClientError. If the stream ended without a trailer, Recv returns
+// an error with CodeTransportError, such that a partial stream is never mistaken for a complete one.
+func (s *StreamReader) Recv(out proto.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	flags, payload, err := readFrame(s.body, s.limit)
+	if err != nil {
+		var limitErr *ErrDataLimitExceeded
+		if errors.As(err, &limitErr) {
+			s.err = NewClientError("", fmt.Errorf("stream message %s; see Client.ResponseLimit",
+				limitErr.Message()), map[string]string{
+				DetailsResponseLimit: strconv.FormatInt(limitErr.Max, 10),
+				DetailsHttpUrl:       s.req.URL.String(),
+				DetailsHttpMethod:    s.req.Method,
+				DetailsHttpStatus:    s.resp.Status,
+			})
+			return s.err
+		}
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		s.err = s.transportError("while reading stream: %w", err)
+		return s.err
+	}
+
+	switch flags {
+	case frameMessage:
+		if err := s.codec.Unmarshal(payload, out); err != nil {
+			return NewClientError("while parsing stream message: %w", err, map[string]string{
+				DetailsHttpUrl:    s.req.URL.String(),
+				DetailsHttpMethod: s.req.Method,
+				DetailsHttpStatus: s.resp.Status,
+			})
+		}
+		return nil
+	case frameTrailer:
+		var reply v1.Reply
+		if err := unmarshalReply(s.codec, payload, &reply); err != nil {
+			s.err = s.transportError("while parsing stream trailer: %w", err)
+			return s.err
+		}
+		s.trailer = &reply
+		if reply.Code == CodeOK {
+			s.err = io.EOF
+		} else {
+			s.err = NewReplyError(s.req, s.resp, &reply)
+		}
+		return s.err
+	}
+	s.err = s.transportError("while reading stream: %w", fmt.Errorf("unknown frame flags '0x%02x'", flags))
+	return s.err
+}
+
+// Trailer returns the v1.Reply which terminated the stream, or nil if the stream has not yet
+// been terminated by the server.
+func (s *StreamReader) Trailer() *v1.Reply {
+	return s.trailer
+}
+
+// Close closes the underlying response body, if the stream has not been terminated by the
+// server, closing the stream cancels the rest of the stream.
+func (s *StreamReader) Close() error {
+	return s.resp.Body.Close()
+}
+
+func (s *StreamReader) transportError(msg string, err error) error {
+	return &ClientError{
+		err: fmt.Errorf(msg, err),
+		details: map[string]string{
+			DetailsHttpUrl:    s.req.URL.String(),
+			DetailsHttpMethod: s.req.Method,
+			DetailsHttpStatus: s.resp.Status,
+		},
+		code: CodeTransportError,
+	}
+}
+
+// RequestStream reads a stream of messages sent by the client in the request body.
+// Use ReadRequestStream() to create a RequestStream.
+type RequestStream struct {
+	body  io.ReadCloser
+	buf   *bufio.Reader
+	codec Codec
+	limit int64
+	err   error
+}
+
+// ReadRequestStream returns a RequestStream which reads the messages streamed by the client in the request
+// body one at a time, such that the entire request is never buffered into memory. Each message may not
+// exceed 'limit' bytes, a limit of zero or less means no limit. If the request `Content-Type` is not one
+// the stream content type of one of the codecs returned by CodecsFromContext() then it returns an error with
+// CodeClientContentError.
+//
+// Once the stream is complete, the handler replies using Reply() or ReplyError() as it would any other
+// method call.
+//
+//	stream, err := duh.ReadRequestStream(r, duh.MegaByte)
+//	if err != nil {
+//		duh.ReplyError(w, r, err)
+//		return
+//	}
+//	defer stream.Close()
+//
+//	for {
+//		var item v1.Item
+//		if err := stream.Recv(&item); err != nil {
+//			if errors.Is(err, io.EOF) {
+//				break
+//			}
+//			duh.ReplyError(w, r, err)
+//			return
+//		}
+//	}
+func ReadRequestStream(r *http.Request, limit int64) (*RequestStream, error) {
+	codecs := CodecsFromContext(r.Context())
+	codec, ok := streamCodec(codecs, TrimSuffix(r.Header.Get("Content-Type"), ";,"))
+	if !ok {
+		return nil, NewServiceError(CodeClientContentError,
+			fmt.Sprintf("Content-Type header '%s' is invalid format or unrecognized content type, "+
+				"only [%s] are supported by this method", r.Header.Get("Content-Type"),
+				streamContentTypes(codecs)), nil, nil)
+	}
+
+	body, err := decompress(CompressionFromContext(r.Context()), r.Header.Get("Content-Encoding"),
+		r.Body, CodeClientContentError)
+	if err != nil {
+		return nil, requestBodyError(err)
+	}
+
+	return &RequestStream{
+		buf:   bufio.NewReader(body),
+		body:  body,
+		codec: codec,
+		limit: limit,
+	}, nil
+}
+
+// Recv reads the next message from the request stream into the proto.Message provided. Recv returns io.EOF
+// once the client has ended the stream with a trailer. If the request body ends without a trailer, Recv
+// returns an error with CodeTransportError as the client likely went away.
+func (s *RequestStream) Recv(m proto.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	flags, payload, err := readFrame(s.buf, s.limit)
+	if err != nil {
+		var e Error
+		switch {
+		case errors.As(err, &e):
+			s.err = NewServiceError(e.Code(), fmt.Sprintf("request message %s", e.Message()), nil, nil)
+		case errors.Is(err, io.EOF):
+			s.err = NewServiceError(CodeTransportError, "request stream ended without a trailer", nil, nil)
+		default:
+			s.err = NewServiceError(CodeTransportError, "while reading request stream", err, nil)
+		}
+		return s.err
+	}
+
+	switch flags {
+	case frameMessage:
+		if err := s.codec.Unmarshal(payload, m); err != nil {
+			// The parser error describes what is wrong with the message, such that it is sent to the client
+			return NewServiceError(CodeClientContentError, err.Error(), err, nil)
+		}
+		return nil
+	case frameTrailer:
+		s.err = io.EOF
+		return s.err
+	}
+	s.err = NewServiceError(CodeClientContentError,
+		fmt.Sprintf("unknown frame flags '0x%02x' in request stream", flags), nil, nil)
+	return s.err
+}
+
+// Close closes the request body
+func (s *RequestStream) Close() error {
+	return s.body.Close()
+}
+
+// StreamBody is an io.ReadCloser which is used as the body of an http.Request to stream
+// many messages to the server in a single request.
+//
+// StreamBody is backed by an io.Pipe, as such Send() blocks until the message is read by the
+// http.Client, and messages MUST be sent from a different go routine than the one making the request.
+//
+//	body, err := duh.NewStreamBody(duh.ContentTypeProtoBufStream)
+//	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+//	req.Header.Set("Content-Type", duh.ContentTypeProtoBufStream)
+//	req.Header.Set("Accept", duh.ContentTypeProtoBuf)
+//
+//	go func() {
+//		for _, item := range items {
+//			if err := body.Send(item); err != nil {
+//				return
+//			}
+//		}
+//		_ = body.CloseSend()
+//	}()
+//
+//	var resp v1.Reply
+//	err = client.Do(req, &resp)
+type StreamBody struct {
+	pr    *io.PipeReader
+	pw    *io.PipeWriter
+	codec Codec
+}
+
+// NewStreamBody returns a new StreamBody which encodes messages using the stream content type provided,
+// IE: ContentTypeProtoBufStream. The codec for the stream content type is found via DefaultCodecs.
+func NewStreamBody(contentType string) (*StreamBody, error) {
+	return newStreamBody(DefaultCodecs, contentType)
+}
+
+func newStreamBody(codecs *Codecs, contentType string) (*StreamBody, error) {
+	codec, ok := streamCodec(codecs, contentType)
+	if !ok {
+		return nil, NewClientError(fmt.Sprintf("unsupported stream content type '%s', only [%s] are supported",
+			contentType, streamContentTypes(codecs)), nil, nil)
+	}
+	pr, pw := io.Pipe()
+	return &StreamBody{pr: pr, pw: pw, codec: codec}, nil
+}
+
+// Send marshals and sends the message to the server. Returns an error if the request has
+// completed or was cancelled before the message could be sent.
+func (b *StreamBody) Send(m proto.Message) error {
+	payload, err := b.codec.Marshal(m)
+	if err != nil {
+		return NewClientError("while marshalling stream message: %w", err, nil)
+	}
+	if err := writeFrame(b.pw, frameMessage, payload); err != nil {
+		return NewClientError("while sending stream message: %w", err, nil)
+	}
+	return nil
+}
+
+// CloseSend ends the stream with a trailer, informing the server that all messages have been sent.
+func (b *StreamBody) CloseSend() error {
+	trailer := &v1.Reply{Code: CodeOK, CodeText: CodeText(CodeOK)}
+	payload, err := replyCodec(b.codec, trailer).Marshal(trailer)
+	if err != nil {
+		return NewClientError("while marshalling stream trailer: %w", err, nil)
+	}
+	if err := writeFrame(b.pw, frameTrailer, payload); err != nil {
+		return NewClientError("while sending stream trailer: %w", err, nil)
+	}
+	return b.pw.Close()
+}
+
+// CloseWithError aborts the stream without a trailer, such that the server does not
+// mistake the partial stream for a complete one.
+func (b *StreamBody) CloseWithError(err error) {
+	_ = b.pw.CloseWithError(err)
+}
+
+// Read implements io.Reader and is called by the http.Client to read the request body
+func (b *StreamBody) Read(p []byte) (int, error) {
+	return b.pr.Read(p)
+}
+
+// Close implements io.Closer and is called by the http.Client once the request is complete,
+// any further calls to Send() will return an error.
+func (b *StreamBody) Close() error {
+	return b.pr.Close()
+}
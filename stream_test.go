@@ -0,0 +1,269 @@
+/*
+Copyright 2023 Derrick J Wippler
+
+Licensed under the MIT License, you may obtain a copy of the License at
+
+https://opensource.org/license/mit/ or in the root of this code repo
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package duh_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duh-rpc/duh-go"
+	"github.com/duh-rpc/duh-go/internal/test"
+	v1 "github.com/duh-rpc/duh-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := duh.NewStreamWriter(w, r)
+		if err != nil {
+			duh.ReplyError(w, r, err)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/test.stream":
+			for i := 0; i < 3; i++ {
+				if err := s.Send(&test.ErrorsRequest{Case: fmt.Sprintf("item-%d", i)}); err != nil {
+					_ = s.Close(err)
+					return
+				}
+			}
+			_ = s.Close(nil)
+		case "/v1/test.mid-stream-error":
+			_ = s.Send(&test.ErrorsRequest{Case: "item-0"})
+			_ = s.Close(duh.NewServiceError(duh.CodeRequestFailed, "database went away", nil,
+				map[string]string{"key": "value"}))
+		case "/v1/test.early-error":
+			_ = s.Close(duh.NewServiceError(duh.CodeNotFound, "no such thing", nil, nil))
+		case "/v1/test.invalid-message":
+			_ = s.Send(&v1.Reply{Code: duh.CodeOK, Message: "not the expected message"})
+			_ = s.Close(nil)
+		case "/v1/test.truncated":
+			// Return without closing the stream
+			_ = s.Send(&test.ErrorsRequest{Case: "item-0"})
+		}
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	do := func(path, accept string) (*duh.StreamReader, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+path, nil)
+		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return duh.DefaultClient.DoStream(req)
+	}
+
+	for _, accept := range []string{"", duh.ContentTypeJSONStream, duh.ContentTypeProtoBufStream} {
+		t.Run("receives all messages "+accept, func(t *testing.T) {
+			stream, err := do("/v1/test.stream", accept)
+			require.NoError(t, err)
+			defer func() { _ = stream.Close() }()
+
+			var items []string
+			for {
+				var m test.ErrorsRequest
+				if err := stream.Recv(&m); err != nil {
+					require.ErrorIs(t, err, io.EOF)
+					break
+				}
+				items = append(items, m.Case)
+			}
+			assert.Equal(t, []string{"item-0", "item-1", "item-2"}, items)
+			require.NotNil(t, stream.Trailer())
+			assert.Equal(t, int32(duh.CodeOK), stream.Trailer().Code)
+
+			// Continues to return io.EOF
+			assert.ErrorIs(t, stream.Recv(&test.ErrorsRequest{}), io.EOF)
+		})
+	}
+
+	t.Run("mid stream error", func(t *testing.T) {
+		stream, err := do("/v1/test.mid-stream-error", duh.ContentTypeProtoBufStream)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		var m test.ErrorsRequest
+		require.NoError(t, stream.Recv(&m))
+		assert.Equal(t, "item-0", m.Case)
+
+		err = stream.Recv(&m)
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeRequestFailed, e.Code())
+		assert.Equal(t, "database went away", e.Message())
+		assert.Equal(t, "value", e.Details()["key"])
+		assert.Equal(t, int32(duh.CodeRequestFailed), stream.Trailer().Code)
+	})
+
+	t.Run("error before stream", func(t *testing.T) {
+		_, err := do("/v1/test.early-error", duh.ContentTypeJSONStream)
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeNotFound, e.Code())
+		assert.Equal(t, "no such thing", e.Message())
+	})
+
+	t.Run("stream without trailer", func(t *testing.T) {
+		stream, err := do("/v1/test.truncated", duh.ContentTypeJSONStream)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		var m test.ErrorsRequest
+		require.NoError(t, stream.Recv(&m))
+		err = stream.Recv(&m)
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeTransportError, e.Code())
+		assert.Contains(t, err.Error(), "unexpected EOF")
+		assert.Nil(t, stream.Trailer())
+	})
+
+	t.Run("invalid message", func(t *testing.T) {
+		stream, err := do("/v1/test.invalid-message", duh.ContentTypeJSONStream)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		err = stream.Recv(&test.ErrorsRequest{})
+		var e *duh.ClientError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientError, e.Code())
+		assert.Contains(t, err.Error(), "while parsing stream message")
+		assert.NotContains(t, err.Error(), "not the expected message")
+	})
+
+	t.Run("does not modify the request", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.stream", nil)
+		require.NoError(t, err)
+		stream, err := duh.DefaultClient.DoStream(req)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+		assert.Equal(t, "", req.Header.Get("Accept"))
+	})
+
+	t.Run("unsupported accept", func(t *testing.T) {
+		_, err := do("/v1/test.stream", duh.ContentTypeJSON)
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientContentError, e.Code())
+		assert.Contains(t, e.Message(), "Accept header 'application/json' is invalid format")
+	})
+}
+
+func TestRequestStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, err := duh.ReadRequestStream(r, duh.Kibibyte)
+		if err != nil {
+			duh.ReplyError(w, r, err)
+			return
+		}
+		defer func() { _ = stream.Close() }()
+
+		var count int
+		for {
+			var m test.ErrorsRequest
+			if err := stream.Recv(&m); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				duh.ReplyError(w, r, err)
+				return
+			}
+			count++
+		}
+		duh.ReplyWithCode(w, r, duh.CodeOK, nil, fmt.Sprintf("received %d messages", count))
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	do := func(contentType string, send func(body *duh.StreamBody)) (*v1.Reply, error) {
+		body, err := duh.NewStreamBody(contentType)
+		require.NoError(t, err)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.upload", body)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", duh.ContentTypeProtoBuf)
+
+		go send(body)
+		var resp v1.Reply
+		return &resp, duh.DefaultClient.Do(req, &resp)
+	}
+
+	for _, ct := range []string{duh.ContentTypeJSONStream, duh.ContentTypeProtoBufStream} {
+		t.Run("receives all messages "+ct, func(t *testing.T) {
+			resp, err := do(ct, func(body *duh.StreamBody) {
+				for i := 0; i < 100; i++ {
+					if err := body.Send(&test.ErrorsRequest{Case: fmt.Sprintf("item-%d", i)}); err != nil {
+						return
+					}
+				}
+				_ = body.CloseSend()
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "received 100 messages", resp.Message)
+		})
+	}
+
+	t.Run("message exceeds limit", func(t *testing.T) {
+		_, err := do(duh.ContentTypeProtoBufStream, func(body *duh.StreamBody) {
+			_ = body.Send(&test.ErrorsRequest{Case: "small"})
+			if err := body.Send(&test.ErrorsRequest{Case: strings.Repeat("a", 2*duh.Kibibyte)}); err != nil {
+				return
+			}
+			_ = body.CloseSend()
+		})
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeBadRequest, e.Code())
+		assert.Equal(t, "request message exceeds 1.0KiB limit", e.Message())
+	})
+
+	t.Run("stream without trailer", func(t *testing.T) {
+		_, err := do(duh.ContentTypeJSONStream, func(body *duh.StreamBody) {
+			_ = body.Send(&test.ErrorsRequest{Case: "item-0"})
+			body.CloseWithError(errors.New("client gave up"))
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/test.upload", nil)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", duh.ContentTypeJSON)
+
+		var resp v1.Reply
+		err = duh.DefaultClient.Do(req, &resp)
+		var e duh.Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, duh.CodeClientContentError, e.Code())
+	})
+
+	t.Run("unsupported stream body", func(t *testing.T) {
+		_, err := duh.NewStreamBody(duh.ContentTypeJSON)
+		require.Error(t, err)
+	})
+}